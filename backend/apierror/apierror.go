@@ -0,0 +1,181 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm/schema"
+)
+
+// Codes for errors that clients are expected to handle on their own. Any
+// other error has the code of its status, e.g. not_found for 404.
+const (
+	CodeValidation       = "validation_failed"       // 422, details.fields
+	CodeRuleViolation    = "rule_violation"          // 422, details.violations
+	CodeDuplicate        = "duplicate"               // 409, details.fields
+	CodeInvalidReference = "invalid_reference"       // 422, details.fields
+	CodeMissingValue     = "missing_value"           // 422, details.fields
+	CodeInvalidValue     = "invalid_value"           // 400
+	CodeStillReferenced  = "still_referenced"        // 409
+	CodeHasDependents    = "has_dependents"          // 409, details.dependents
+	CodeParentTrashed    = "parent_trashed"          // 409, details.parents
+	CodeVersionMismatch  = "version_mismatch"        // 412, details.current
+	CodeIfMatchRequired  = "if_match_required"       // 428
+	CodeBulkFailed       = "bulk_failed"             // 422
+	CodeNotSaved         = "not_saved"               // 424, bulk items rolled back
+	CodeKeyReused        = "idempotency_key_reused"  // 422
+	CodeKeyInProgress    = "idempotency_in_progress" // 409
+	CodeInvalidCSRF      = "invalid_csrf_token"      // 403
+	CodeLoginLocked      = "login_locked"            // 429, details.retry_after
+)
+
+// Error is an error answered to the client, always as {"error": Error}
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   gin.H  `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New is an error with the code of its status
+func New(status int, message string) *Error {
+	return &Error{Status: status, Code: statusCode(status), Message: message}
+}
+
+// statusCode turns a status into a code, e.g. 404 into not_found
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// Abort answers an error with the status and message and stops the chain
+func Abort(c *gin.Context, status int, message string) {
+	Respond(c, New(status, message))
+}
+
+// Respond answers err and stops the chain
+func Respond(c *gin.Context, err error) {
+	e := From(c, err)
+	c.AbortWithStatusJSON(e.Status, gin.H{"error": e})
+}
+
+// From turns err into the error answered for it: an *Error as it is, a
+// constraint violation with its status, and anything else as a 500 that is
+// logged with the request ID. The cause of a 500 is only shown outside
+// release mode (GIN_MODE=release).
+func From(c *gin.Context, err error) *Error {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = FromDB(err, nil)
+	}
+	if e == nil {
+		log.Printf("Request %s failed: %v", c.GetString("request_id"), err)
+		e = New(http.StatusInternalServerError, "Internal server error")
+		if gin.Mode() != gin.ReleaseMode {
+			e.Details = gin.H{"cause": err.Error()}
+		}
+	}
+
+	// Copy, as errors are often shared variables
+	out := *e
+	if out.Code == "" {
+		out.Code = statusCode(out.Status)
+	}
+	out.RequestID = c.GetString("request_id")
+	return &out
+}
+
+// FromDB turns a Postgres constraint violation into an error naming the
+// fields involved, or returns nil for any other error. With the schema of
+// the model written, fields are named as in the API rather than by column.
+func FromDB(err error, sch *schema.Schema) *Error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	switch {
+	case pgErr.Code == "23505": // unique_violation
+		fields := keyFields(pgErr.Detail, sch)
+		return &Error{
+			Status:  http.StatusConflict,
+			Code:    CodeDuplicate,
+			Message: fmt.Sprintf("Another %s already has this %s", resourceName(sch), joinFields(fields)),
+			Details: gin.H{"fields": fields},
+		}
+	case pgErr.Code == "23503" && strings.Contains(pgErr.Detail, "is still referenced"): // foreign_key_violation
+		return &Error{
+			Status:  http.StatusConflict,
+			Code:    CodeStillReferenced,
+			Message: "Cannot delete, other resources still refer to it",
+		}
+	case pgErr.Code == "23503":
+		fields := keyFields(pgErr.Detail, sch)
+		return &Error{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeInvalidReference,
+			Message: fmt.Sprintf("%s refers to a resource that doesn't exist", joinFields(fields)),
+			Details: gin.H{"fields": fields},
+		}
+	case pgErr.Code == "23502": // not_null_violation
+		fields := []string{fieldName(pgErr.ColumnName, sch)}
+		return &Error{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeMissingValue,
+			Message: fmt.Sprintf("%s is required", fields[0]),
+			Details: gin.H{"fields": fields},
+		}
+	case strings.HasPrefix(pgErr.Code, "22"): // data_exception, e.g. a word for a number
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidValue,
+			Message: "Invalid value",
+		}
+	}
+	return nil
+}
+
+// keyFields reads the columns from a violation's detail, e.g.
+// "Key (tenant_id, code)=(1, CS101) already exists.", leaving out the tenant
+func keyFields(detail string, sch *schema.Schema) []string {
+	columns, _, _ := strings.Cut(strings.TrimPrefix(detail, "Key ("), ")=")
+	var fields []string
+	for _, column := range strings.Split(columns, ", ") {
+		if column != "tenant_id" && column != "" {
+			fields = append(fields, fieldName(column, sch))
+		}
+	}
+	return fields
+}
+
+func joinFields(fields []string) string {
+	if len(fields) == 0 {
+		return "value"
+	}
+	return strings.Join(fields, " and ")
+}
+
+func fieldName(column string, sch *schema.Schema) string {
+	if sch != nil {
+		if field := sch.LookUpField(column); field != nil {
+			return field.Name
+		}
+	}
+	return column
+}
+
+func resourceName(sch *schema.Schema) string {
+	if sch == nil {
+		return "row"
+	}
+	return strings.ToLower(sch.Name)
+}
@@ -30,10 +30,10 @@ func ConnectDB() {
 	sslmode := os.Getenv("PG_SSLMODE")
 
     // Connection pool configuration
-    maxConns := getEnvAsInt("PG_MAX_CONNS", 25)
-    minConns := getEnvAsInt("PG_MIN_CONNS", 5)
-    maxConnLifetime := getEnvAsDuration("PG_MAX_CONN_LIFETIME", "1h")
-    maxConnIdleTime := getEnvAsDuration("PG_MAX_CONN_IDLE_TIME", "30m")
+    maxConns := GetEnvAsInt("PG_MAX_CONNS", 25)
+    minConns := GetEnvAsInt("PG_MIN_CONNS", 5)
+    maxConnLifetime := GetEnvAsDuration("PG_MAX_CONN_LIFETIME", "1h")
+    maxConnIdleTime := GetEnvAsDuration("PG_MAX_CONN_IDLE_TIME", "30m")
 
     dsn := fmt.Sprintf(
         "host=%s port=%s user=%s password=%s dbname=%s sslmode=%s connect_timeout=10",
@@ -95,7 +95,7 @@ func ConnectDB() {
         Logger: logger.Default.LogMode(logger.Info),
         // Additional GORM configuration to handle connection pooling better
         PrepareStmt:                              false, // Disable prepared statement caching
-        DisableForeignKeyConstraintWhenMigrating: true,  // Additional safety for pooled connections
+        DisableForeignKeyConstraintWhenMigrating: false, // Foreign keys enforce the delete policies in models.Dependencies
     })
     if err != nil {
         log.Fatalf("Failed to connect to database with GORM: %v", err)
@@ -105,8 +105,8 @@ func ConnectDB() {
     log.Println("✅ Successfully connected to database with connection pooling over IPv4")
 }
 
-// Helper function to get environment variable as integer with default value
-func getEnvAsInt(name string, defaultVal int) int {
+// GetEnvAsInt gets an environment variable as integer with default value
+func GetEnvAsInt(name string, defaultVal int) int {
     valueStr := os.Getenv(name)
     if value, err := strconv.Atoi(valueStr); err == nil {
         return value
@@ -114,8 +114,8 @@ func getEnvAsInt(name string, defaultVal int) int {
     return defaultVal
 }
 
-// Helper function to get environment variable as duration with default value
-func getEnvAsDuration(name string, defaultVal string) time.Duration {
+// GetEnvAsDuration gets an environment variable as duration with default value
+func GetEnvAsDuration(name string, defaultVal string) time.Duration {
     valueStr := os.Getenv(name)
     if valueStr == "" {
         valueStr = defaultVal
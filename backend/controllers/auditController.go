@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// auditRedacted are fields never copied into the audit log
+var auditRedacted = []string{"Password"}
+
+// recordAudit appends a change of the model to the audit log within tx, so
+// the entry is only kept if the change is committed. before and after are
+// nil for creates and deletes respectively.
+func recordAudit(c *gin.Context, tx *gorm.DB, action string, model any, before, after any) error {
+	entry := models.AuditLog{
+		ActorID:       c.GetUint("user_id"),
+		ActorUsername: c.GetString("username"),
+		Action:        action,
+		ResourceType:  resourceType(model),
+		ResourceID:    modelID(model),
+	}
+	if impersonator := c.GetString("impersonator"); impersonator != "" {
+		entry.ActorID = c.GetUint("impersonator_id")
+		entry.ActorUsername = impersonator
+		entry.AsUsername = c.GetString("username")
+	}
+
+	var err error
+	if entry.Before, err = auditJSON(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditJSON(after); err != nil {
+		return err
+	}
+	return tx.Create(&entry).Error
+}
+
+// auditJSON snapshots a model's columns as JSON, without sensitive fields
+func auditJSON(v any) (models.JSON, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if json.Unmarshal(data, &fields) != nil {
+		return data, nil
+	}
+	for _, name := range auditRedacted {
+		delete(fields, name)
+	}
+	// Only the row's own columns are recorded, not associations that may or
+	// may not have been loaded. Lists of IDs are kept.
+	for name, value := range fields {
+		switch value := value.(type) {
+		case map[string]any:
+			delete(fields, name)
+		case []any:
+			if slices.ContainsFunc(value, func(item any) bool { _, ok := item.(map[string]any); return ok }) {
+				delete(fields, name)
+			}
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// resourceType names a model the way its routes do, e.g. lecture
+func resourceType(model any) string {
+	return strings.ToLower(reflect.Indirect(reflect.ValueOf(model)).Type().Name())
+}
+
+// GetAuditLogs lists audit log entries, newest first, filtered by actor,
+// resource, action and date range
+func GetAuditLogs(c *gin.Context) {
+	query := tenantDB(c).Order("created_at DESC, id DESC")
+
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor_username = ?", actor)
+	}
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if resource := c.Query("resource"); resource != "" {
+		query = query.Where("resource_type = ?", strings.ToLower(resource))
+	}
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if from := c.Query("from"); from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "invalid 'from' date, use YYYY-MM-DD")
+			return
+		}
+		query = query.Where("created_at >= ?", date)
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "invalid 'to' date, use YYYY-MM-DD")
+			return
+		}
+		// 'to' is inclusive of the whole day
+		query = query.Where("created_at < ?", date.AddDate(0, 0, 1))
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			apierror.Abort(c, http.StatusBadRequest, "Invalid 'limit' parameter. Must be a positive number.")
+			return
+		}
+		limit = min(l, 1000)
+	}
+
+	var entries []models.AuditLog
+	if err := query.Limit(limit).Find(&entries).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch audit log")
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
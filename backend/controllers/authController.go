@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
+	"tms-server/apierror"
 	"tms-server/models"
 	"tms-server/utils"
 
@@ -14,24 +18,70 @@ func Ping(c *gin.Context) {
 }
 
 func Login(c *gin.Context) {
-	var input models.User
+	// Not models.User, whose password is never read from JSON
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ip := c.ClientIP()
+	throttle := utils.Throttle()
+	if wait := throttle.Check(c.GetUint("tenant_id"), input.Username, ip); wait > 0 {
+		recordLoginAttempt(c, input.Username, ip, false, "locked")
+		tooManyAttempts(c, wait)
 		return
 	}
 
-	user, err := utils.AuthenticateUser(input.Username, input.Password)
+	user, err := utils.AuthenticateUser(c.Request.Context(), input.Username, input.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		recordLoginAttempt(c, input.Username, ip, false, "invalid_credentials")
+		if wait := throttle.Fail(c.GetUint("tenant_id"), input.Username, ip); wait > 0 {
+			log.Printf("Login locked for user '%s' from %s for %s", input.Username, ip, wait)
+			tooManyAttempts(c, wait)
+			return
+		}
+		apierror.Abort(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	token, err := utils.GenerateToken(user.Username, user.Role)
+	if user.TOTPEnabled || utils.TOTPRequired(user.Role) {
+		requireSecondFactor(c, user)
+		return
+	}
+
+	throttle.Succeed(c.GetUint("tenant_id"), input.Username)
+	recordLoginAttempt(c, input.Username, ip, true, "")
+	issueSession(c, user, nil)
+}
+
+// issueSession sets the auth cookie for a fully authenticated user and
+// responds with the login payload, plus any extra fields
+func issueSession(c *gin.Context, user *models.User, extra gin.H) {
+	token, err := utils.GenerateToken(user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		apierror.Abort(c, http.StatusInternalServerError, "Could not generate token")
 		return
 	}
 
+	setAuthCookie(c, token)
+
+	response := gin.H{
+		"message":    "Login Successful",
+		"username":   user.Username,
+		"role":       user.Role,
+		"csrf_token": utils.CSRFToken(token),
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func setAuthCookie(c *gin.Context, token string) {
 	// Set cookie with SameSite=None; Secure for cross-site/mobile compatibility
 	http.SetCookie(c.Writer, &http.Cookie{
 		Name:     "auth_token",
@@ -42,12 +92,44 @@ func Login(c *gin.Context) {
 		Secure:   true,
 		SameSite: http.SameSiteNoneMode,
 	})
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "Login Successful",
-		"username": user.Username,
-		"role":     user.Role,
-	})
+// currentUser loads the user the request was authenticated as
+func currentUser(c *gin.Context) (*models.User, error) {
+	var user models.User
+	query := tenantDB(c).Where("username = ?", c.GetString("username"))
+	if userID := c.GetUint("user_id"); userID != 0 {
+		query = tenantDB(c).Where("id = ?", userID)
+	}
+	if err := query.First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// currentFaculty loads the faculty record linked to the authenticated user
+func currentFaculty(c *gin.Context) (*models.Faculty, error) {
+	user, err := currentUser(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var faculty models.Faculty
+	if err := tenantDB(c).Where("user_id = ?", user.ID).First(&faculty).Error; err != nil {
+		return nil, err
+	}
+	return &faculty, nil
+}
+
+// GetCSRFToken returns the CSRF token for the current cookie session, for
+// clients that lost the one handed out at login (e.g. after a page reload)
+func GetCSRFToken(c *gin.Context) {
+	token, err := c.Cookie("auth_token")
+	if err != nil {
+		apierror.Abort(c, http.StatusBadRequest, "No cookie session")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"csrf_token": utils.CSRFToken(token)})
 }
 
 func Logout(c *gin.Context) {
@@ -65,3 +147,94 @@ func Logout(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
+
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	apierror.Respond(c, &apierror.Error{
+		Status:  http.StatusTooManyRequests,
+		Code:    apierror.CodeLoginLocked,
+		Message: "Too many failed login attempts, try again later",
+		Details: gin.H{"retry_after": seconds},
+	})
+}
+
+func recordLoginAttempt(c *gin.Context, username, ip string, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		Username: username,
+		IP:       ip,
+		Success:  success,
+		Reason:   reason,
+	}
+	if err := tenantDB(c).Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt for user '%s': %v", username, err)
+	}
+}
+
+// GetLoginAttempts lists the login attempt history, newest first
+func GetLoginAttempts(c *gin.Context) {
+	query := tenantDB(c).Order("created_at DESC")
+
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if successStr := c.Query("success"); successStr != "" {
+		success, err := strconv.ParseBool(successStr)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "Invalid 'success' parameter. Must be true or false.")
+			return
+		}
+		query = query.Where("success = ?", success)
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			apierror.Abort(c, http.StatusBadRequest, "Invalid 'limit' parameter. Must be a positive number.")
+			return
+		}
+		limit = min(l, 1000)
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Limit(limit).Find(&attempts).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch login attempts")
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+// GetLoginLockouts lists usernames and IPs that are currently locked out
+func GetLoginLockouts(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.Throttle().Lockouts(c.GetUint("tenant_id")))
+}
+
+// UnlockLogin clears the lockout on a username and/or IP
+func UnlockLogin(c *gin.Context) {
+	var input struct {
+		Username string `json:"username"`
+		IP       string `json:"ip"`
+		UserID   uint   `json:"user_id"` // second-factor codes, locked by user ID
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Username == "" && input.IP == "" && input.UserID == 0 {
+		apierror.Abort(c, http.StatusBadRequest, "username, ip or user_id is required")
+		return
+	}
+
+	utils.Throttle().Unlock(c.GetUint("tenant_id"), input.Username, input.IP)
+	if input.UserID != 0 {
+		utils.Throttle().SucceedCode(c.GetUint("tenant_id"), input.UserID)
+	}
+	log.Printf("Login lockout cleared by '%s' for user '%s' ip '%s' user ID %d", c.GetString("username"), input.Username, input.IP, input.UserID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unlocked"})
+}
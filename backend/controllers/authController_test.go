@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tms-server/config"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB stands in for the database with one that builds statements
+// without running them, for handlers whose writes are incidental to a test
+func dryRunDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = original })
+}
+
+// stubAuthenticator knows one user and their password hash
+type stubAuthenticator struct {
+	user *models.User
+	hash string
+}
+
+func (stubAuthenticator) Name() string { return "stub" }
+
+func (a stubAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	if username != a.user.Username {
+		return nil, utils.ErrUnknownUser
+	}
+	if !utils.CheckPassword(a.hash, password) {
+		return nil, utils.ErrInvalidCredentials
+	}
+	return a.user, nil
+}
+
+func TestLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dryRunDB(t)
+	t.Setenv("APP_JWT_SECRET", "test-secret")
+	t.Setenv("TOTP_REQUIRED_ROLES", "")
+
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := utils.Authenticators
+	utils.Authenticators = []utils.Authenticator{stubAuthenticator{&models.User{ID: 1, Username: "admin", Role: "admin"}, hash}}
+	t.Cleanup(func() { utils.Authenticators = original })
+
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"correct password", `{"username": "admin", "password": "correct horse"}`, http.StatusOK},
+		{"field names in any case", `{"Username": "admin", "Password": "correct horse"}`, http.StatusOK},
+		{"wrong password", `{"username": "admin", "password": "wrong"}`, http.StatusUnauthorized},
+		{"missing password", `{"username": "admin"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			Login(c)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && !strings.Contains(w.Header().Get("Set-Cookie"), "auth_token=") {
+				t.Error("no auth cookie set")
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"tms-server/apierror"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBulkItems caps the rows one bulk request can write
+const maxBulkItems = 1000
+
+const (
+	bulkAtomic  = "atomic"  // nothing is saved unless every item succeeds
+	bulkPartial = "partial" // items that succeed are saved, the rest reported
+)
+
+// errBulkFailed rolls back an atomic bulk request with failed items
+var errBulkFailed = errors.New("bulk request failed")
+
+// BulkCreate creates every object in a JSON array (POST /<resource>/bulk)
+func BulkCreate[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
+	return bulk(db, http.StatusCreated, func(c *gin.Context, tx *gorm.DB, raw json.RawMessage) (any, error) {
+		var model T
+		if err := res.decode(raw, &model); err != nil {
+			return nil, apierror.New(http.StatusBadRequest, err.Error())
+		}
+		if err := createRow(c, tx, res, &model); err != nil {
+			return nil, err
+		}
+		return res.view(&model), nil
+	})
+}
+
+// BulkPatch applies a JSON Merge Patch per array item (PATCH /<resource>/bulk).
+// Each item names its row with ID and may give the Version it was based on,
+// checked like If-Match.
+func BulkPatch[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
+	return bulk(db, http.StatusOK, func(c *gin.Context, tx *gorm.DB, raw json.RawMessage) (any, error) {
+		doc, err := decodeObject(raw)
+		if err != nil {
+			return nil, apierror.New(http.StatusBadRequest, err.Error())
+		}
+		id, ifMatch, err := bulkItemRow(doc)
+		if err != nil {
+			return nil, err
+		}
+		model, err := updateRow(c, tx, res, id, doc, false, ifMatch)
+		if err != nil {
+			return nil, err
+		}
+		return res.view(model), nil
+	})
+}
+
+// BulkDelete deletes every row in a JSON array of IDs, or of objects with
+// ID and optionally Version (DELETE /<resource>/bulk)
+func BulkDelete[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
+	return bulk(db, http.StatusOK, func(c *gin.Context, tx *gorm.DB, raw json.RawMessage) (any, error) {
+		var id uint
+		var ifMatch string
+		if json.Unmarshal(raw, &id) != nil {
+			doc, err := decodeObject(raw)
+			if err != nil {
+				return nil, apierror.New(http.StatusBadRequest, "Item must be an ID or an object with ID")
+			}
+			if id, ifMatch, err = bulkItemRow(doc); err != nil {
+				return nil, err
+			}
+		}
+		if err := deleteRow(c, tx, res, id, ifMatch); err != nil {
+			return nil, err
+		}
+		return gin.H{"ID": id}, nil
+	})
+}
+
+// bulkItemRow reads the ID and Version keys of a bulk item, the Version
+// turned into an If-Match entity tag
+func bulkItemRow(doc map[string]json.RawMessage) (uint, string, error) {
+	var id uint
+	var ifMatch string
+	for key, raw := range doc {
+		switch {
+		case strings.EqualFold(key, "ID"):
+			if json.Unmarshal(raw, &id) != nil {
+				return 0, "", apierror.New(http.StatusBadRequest, "Invalid value for 'ID'")
+			}
+		case strings.EqualFold(key, "Version"):
+			var version uint
+			if json.Unmarshal(raw, &version) != nil {
+				return 0, "", apierror.New(http.StatusBadRequest, "Invalid value for 'Version'")
+			}
+			ifMatch = strconv.Quote(strconv.FormatUint(uint64(version), 10))
+		}
+	}
+	if id == 0 {
+		return 0, "", apierror.New(http.StatusBadRequest, "Item must have an ID")
+	}
+	return id, ifMatch, nil
+}
+
+// bulk runs write for each item of a JSON array body in one transaction.
+// Each item gets a savepoint, so a failed item is undone on its own and the
+// rest are still tried; ?mode=atomic (the default) then rolls back everything
+// if any item failed, ?mode=partial keeps the items that succeeded.
+func bulk(db *gorm.DB, okStatus int, write func(*gin.Context, *gorm.DB, json.RawMessage) (any, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := forTenant(c, db)
+
+		mode := c.DefaultQuery("mode", bulkAtomic)
+		if mode != bulkAtomic && mode != bulkPartial {
+			apierror.Abort(c, http.StatusBadRequest, "Invalid 'mode' parameter. Must be 'atomic' or 'partial'.")
+			return
+		}
+
+		var items []json.RawMessage
+		if err := c.ShouldBindJSON(&items); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "Body must be a JSON array")
+			return
+		}
+		if len(items) == 0 || len(items) > maxBulkItems {
+			apierror.Abort(c, http.StatusBadRequest, fmt.Sprintf("Send between 1 and %d items", maxBulkItems))
+			return
+		}
+
+		results := make([]gin.H, len(items))
+		failed := 0
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i, item := range items {
+				var data any
+				err := tx.Transaction(func(tx *gorm.DB) error {
+					var err error
+					data, err = write(c, tx, item)
+					return err
+				})
+				results[i] = bulkResult(c, i, okStatus, data, err)
+				if err != nil {
+					failed++
+				}
+			}
+			if failed > 0 && mode == bulkAtomic {
+				return errBulkFailed
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBulkFailed) {
+			apierror.Respond(c, err)
+			return
+		}
+
+		committed := err == nil
+		status := okStatus
+		body := gin.H{}
+		switch {
+		case !committed:
+			// The items that worked were rolled back with the rest
+			for i, result := range results {
+				if result["status"] == okStatus {
+					results[i] = bulkResult(c, i, okStatus, nil, &apierror.Error{
+						Status:  http.StatusFailedDependency,
+						Code:    apierror.CodeNotSaved,
+						Message: "Not saved, another item failed",
+					})
+				}
+			}
+			status = http.StatusUnprocessableEntity
+			body["error"] = apierror.From(c, &apierror.Error{
+				Status:  status,
+				Code:    apierror.CodeBulkFailed,
+				Message: fmt.Sprintf("Nothing was saved, %d of %d items failed", failed, len(items)),
+			})
+		case failed > 0:
+			status = http.StatusMultiStatus
+		}
+
+		succeeded := len(items) - failed
+		if !committed {
+			succeeded = 0
+		}
+		body["mode"] = mode
+		body["committed"] = committed
+		body["succeeded"] = succeeded
+		body["failed"] = failed
+		body["results"] = results
+		c.JSON(status, body)
+	}
+}
+
+// bulkResult reports one item as the single-row endpoint would answer it
+func bulkResult(c *gin.Context, index, okStatus int, data any, err error) gin.H {
+	if err == nil {
+		return gin.H{"index": index, "status": okStatus, "data": data}
+	}
+	e := apierror.From(c, err)
+	return gin.H{"index": index, "status": e.Status, "error": e}
+}
@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"time"
-	"tms-server/config"
+	"tms-server/apierror"
 	"tms-server/models"
 )
 
@@ -17,22 +17,25 @@ func GetCalendarSummaryByMonth(c *gin.Context) {
 	courseID := c.Query("course_id")
 
 	if month == "" || year == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Both 'month' and 'year' query parameters are required.",
-		})
+		apierror.Abort(c, http.StatusBadRequest, "Both 'month' and 'year' query parameters are required.")
 		return
 	}
 
 	if _, err := strconv.Atoi(month); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'month' parameter. Must be a number."})
+		apierror.Abort(c, http.StatusBadRequest, "Invalid 'month' parameter. Must be a number.")
 		return
 	}
 	if _, err := strconv.Atoi(year); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'year' parameter. Must be a number."})
+		apierror.Abort(c, http.StatusBadRequest, "Invalid 'year' parameter. Must be a number.")
 		return
 	}
 
-	query := config.DB.Model(&models.Session{}).
+	query, err := scopeToDepartment(c, tenantDB(c).Model(&models.Session{}), &models.Session{})
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+	query = query.
 		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
 		Where("EXTRACT(MONTH FROM sessions.date) = ?", month).
 		Where("EXTRACT(YEAR FROM sessions.date) = ?", year)
@@ -51,9 +54,9 @@ func GetCalendarSummaryByMonth(c *gin.Context) {
 	}
 
 	var sessions []models.Session
-	err := query.Preload("Lecture").Find(&sessions).Error
+	err = query.Preload("Lecture").Find(&sessions).Error
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetching sessions"})
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetching sessions")
 		return
 	}
 
@@ -102,19 +105,25 @@ func GetLectureDetailsByDate(c *gin.Context) {
 	courseID := c.Query("course_id")
 
 	if dateStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "date is required in YYYY-MM-DD format"})
+		apierror.Abort(c, http.StatusBadRequest, "date is required in YYYY-MM-DD format")
 		return
 	}
 
 	date, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		apierror.Abort(c, http.StatusBadRequest, "invalid date format, use YYYY-MM-DD")
+		return
+	}
+
+	sessionQuery, err := scopeToDepartment(c, tenantDB(c), &models.Session{})
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
 		return
 	}
 
 	var sessions []models.Session
-	if err := config.DB.Where("date = ?", date).Find(&sessions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+	if err := sessionQuery.Where("date = ?", date).Find(&sessions).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch sessions")
 		return
 	}
 
@@ -128,7 +137,7 @@ func GetLectureDetailsByDate(c *gin.Context) {
 		lectureIDs = append(lectureIDs, s.LectureID)
 	}
 
-	lectureQuery := config.DB.
+	lectureQuery := tenantDB(c).
 		Preload("Subject").
 		Preload("Faculty").
 		Preload("Room").
@@ -150,7 +159,7 @@ func GetLectureDetailsByDate(c *gin.Context) {
 
 	var lectures []models.Lecture
 	if err := lectureQuery.Find(&lectures).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lecture details"})
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch lecture details")
 		return
 	}
 
@@ -182,7 +191,7 @@ func GetLectureDetailsByDate(c *gin.Context) {
 			"batch_year":    lecture.Batch.Year,
 			"batch_section": lecture.Batch.Section,
 			"course_name":   lecture.Batch.Course.Name,
-			"session_id":   s.ID,
+			"session_id":    s.ID,
 		})
 	}
 
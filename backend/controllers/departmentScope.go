@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errOutsideDepartment = errors.New("resource belongs to another department")
+
+// callerDepartment returns the department the caller is restricted to, or nil
+// if they may see every department. Users without a department are not
+// restricted, which keeps single-department deployments working unchanged.
+func callerDepartment(c *gin.Context) (*uint, error) {
+	if v, ok := c.Get("department_id"); ok {
+		return v.(*uint), nil
+	}
+
+	var departmentID *uint
+	if !utils.HasPermission(c.GetUint("tenant_id"), c.GetString("role"), models.PermDeptAll) {
+		user, err := currentUser(c)
+		if err != nil {
+			return nil, err
+		}
+		departmentID = user.DepartmentID
+	}
+
+	c.Set("department_id", departmentID)
+	return departmentID, nil
+}
+
+// scopeToDepartment restricts a query on the model's table to the caller's
+// department. Models that don't belong to a department are left unscoped.
+func scopeToDepartment(c *gin.Context, query *gorm.DB, model any) (*gorm.DB, error) {
+	scoped, ok := model.(models.DepartmentScoped)
+	if !ok {
+		return query, nil
+	}
+
+	departmentID, err := callerDepartment(c)
+	if err != nil {
+		return nil, err
+	}
+	if departmentID == nil {
+		return query, nil
+	}
+	return scoped.ScopeToDepartment(query, *departmentID), nil
+}
+
+// checkInDepartment verifies a written row is still visible to the caller, so
+// nobody can create or move a row into another department
+func checkInDepartment[T any](c *gin.Context, tx *gorm.DB, model *T) error {
+	query, err := scopeToDepartment(c, tx.Model(new(T)), model)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := query.Where("id = ?", modelID(model)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errOutsideDepartment
+	}
+	return nil
+}
+
+// assignCallerDepartment defaults the department of a new row to the caller's
+func assignCallerDepartment(c *gin.Context, model any) error {
+	assignable, ok := model.(models.DepartmentAssignable)
+	if !ok {
+		return nil
+	}
+
+	departmentID, err := callerDepartment(c)
+	if err != nil {
+		return err
+	}
+	if departmentID != nil {
+		assignable.AssignDepartment(*departmentID)
+	}
+	return nil
+}
+
+// modelID reads the ID primary key every model in this app has
+func modelID(model any) uint {
+	return uint(reflect.Indirect(reflect.ValueOf(model)).FieldByName("ID").Uint())
+}
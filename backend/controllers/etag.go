@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"tms-server/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errStale is returned when a row changed between reading and writing it
+var errStale = errors.New("the resource was changed by someone else")
+
+func modelVersion(model any) uint {
+	return uint(reflect.Indirect(reflect.ValueOf(model)).FieldByName("Version").Uint())
+}
+
+func setModelVersion(model any, version uint) {
+	reflect.Indirect(reflect.ValueOf(model)).FieldByName("Version").SetUint(uint64(version))
+}
+
+// etag identifies a version of a row, e.g. "3"
+func etag(model any) string {
+	return strconv.Quote(strconv.FormatUint(uint64(modelVersion(model)), 10))
+}
+
+func setETag(c *gin.Context, model any) {
+	c.Header("ETag", etag(model))
+}
+
+// matchesETag reports whether a list of entity tags from If-Match or
+// If-None-Match names the model's version. Weak tags never match.
+func matchesETag(header string, model any) bool {
+	current := etag(model)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// staleError carries the row as it is now, so the client can merge its
+// changes and retry with the new ETag
+func staleError(current any) error {
+	return &apierror.Error{
+		Status:  http.StatusPreconditionFailed,
+		Code:    apierror.CodeVersionMismatch,
+		Message: errStale.Error(),
+		Details: gin.H{"current": current},
+	}
+}
+
+// ifMatchError checks an If-Match header against the row's version. Without
+// the header the write goes ahead, unless REQUIRE_IF_MATCH is set.
+func ifMatchError(header string, model any) error {
+	if header == "" {
+		if os.Getenv("REQUIRE_IF_MATCH") == "true" {
+			return &apierror.Error{
+				Status:  http.StatusPreconditionRequired,
+				Code:    apierror.CodeIfMatchRequired,
+				Message: "If-Match header with the resource's ETag is required",
+			}
+		}
+		return nil
+	}
+	if !matchesETag(header, model) {
+		return staleError(model)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"tms-server/apierror"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// facultySubjects is how a change of a faculty's subjects is audited
+type facultySubjects struct {
+	ID         uint
+	Version    uint
+	SubjectIDs []uint
+}
+
+// SetFacultySubjects replaces the subjects a faculty is assigned to teach.
+// Once a faculty has any, their lectures must be for one of them. Faculty are
+// shared between departments, so a department-scoped caller only replaces
+// the assignments in their department and the others are kept.
+func SetFacultySubjects(c *gin.Context) {
+	var input struct {
+		SubjectIDs []uint `json:"subject_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	slices.Sort(input.SubjectIDs)
+	input.SubjectIDs = slices.Compact(input.SubjectIDs)
+
+	db := tenantDB(c)
+	query, err := scopeToDepartment(c, db, &models.Faculty{})
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+	var faculty models.Faculty
+	if err := query.Preload("Subjects").First(&faculty, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Not found")
+		return
+	}
+	if err := ifMatchError(c.GetHeader("If-Match"), &faculty); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+
+	// Only subjects the caller can see can be assigned, or unassigned
+	subjects, err := visibleSubjects(c, db, input.SubjectIDs)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if len(subjects) != len(input.SubjectIDs) {
+		apierror.Abort(c, http.StatusBadRequest, "unknown subject in subject_ids")
+		return
+	}
+	visible, err := visibleSubjects(c, db, subjectIDs(faculty.Subjects))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	for _, subject := range faculty.Subjects {
+		if !slices.ContainsFunc(visible, func(s models.Subject) bool { return s.ID == subject.ID }) {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	before := facultySubjects{faculty.ID, faculty.Version, subjectIDs(faculty.Subjects)}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Changing the subjects is a change of the faculty, so it takes a
+		// new version like any other write
+		result := tx.Model(&faculty).Where("version = ?", faculty.Version).Update("version", faculty.Version+1)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var current models.Faculty
+			if err := tx.First(&current, faculty.ID).Error; err != nil {
+				return errNotFound
+			}
+			return staleError(&current)
+		}
+		faculty.Version++
+
+		if err := tx.Model(&faculty).Association("Subjects").Replace(subjects); err != nil {
+			return err
+		}
+		after := facultySubjects{faculty.ID, faculty.Version, subjectIDs(subjects)}
+		return recordAudit(c, tx, models.AuditSubjects, &faculty, &before, &after)
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+
+	faculty.Subjects = subjects
+	setETag(c, &faculty)
+	c.JSON(http.StatusOK, faculty)
+}
+
+// visibleSubjects loads the subjects with the given IDs that are in the
+// caller's department
+func visibleSubjects(c *gin.Context, db *gorm.DB, ids []uint) ([]models.Subject, error) {
+	var subjects []models.Subject
+	if len(ids) == 0 {
+		return subjects, nil
+	}
+	query, err := scopeToDepartment(c, db, &models.Subject{})
+	if err != nil {
+		return nil, errNoCaller
+	}
+	return subjects, query.Where("id IN ?", ids).Find(&subjects).Error
+}
+
+func subjectIDs(subjects []models.Subject) []uint {
+	ids := make([]uint, len(subjects))
+	for i, subject := range subjects {
+		ids[i] = subject.ID
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -2,89 +2,249 @@ package controllers
 
 import (
 	"net/http"
-	"reflect"
+	"tms-server/apierror"
+	"tms-server/models"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func All[T any](db *gorm.DB) gin.HandlerFunc {
+// All lists a page of rows, sorted and filtered as the resource's ListSpec
+// allows. The total count and links to other pages are sent as headers.
+func All[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var models []T
-		if err := db.Find(&models).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		db := forTenant(c, db)
+		var rows []T
+		spec := listSpec(res.List)
+
+		p, err := pageOf(c, spec)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		query, err := scopeToDepartment(c, db.Model(new(T)), new(T))
+		if err != nil {
+			apierror.Abort(c, http.StatusUnauthorized, "User not found")
+			return
+		}
+		// New session so counting doesn't leak into the page query
+		query = filterList(c, query, spec).Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			listError(c, err)
+			return
+		}
+
+		query, err = sortList(c, query, spec)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, models)
+
+		query, err = preloadIncludes(c, query, res.Includes)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := p.apply(query).Find(&rows).Error; err != nil {
+			listError(c, err)
+			return
+		}
+
+		setPageHeaders(c, p, total)
+		c.JSON(http.StatusOK, res.viewAll(rows))
 	}
 }
 
-func Get[T any](db *gorm.DB) gin.HandlerFunc {
+func Get[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := forTenant(c, db)
 		id := c.Param("id")
 		var model T
-		
-		if err := db.First(&model, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+
+		query, err := scopeToDepartment(c, db, &model)
+		if err != nil {
+			apierror.Abort(c, http.StatusUnauthorized, "User not found")
 			return
 		}
-		
-		c.JSON(http.StatusOK, model)
+
+		query, err = preloadIncludes(c, query, res.Includes)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := query.First(&model, id).Error; err != nil {
+			apierror.Abort(c, http.StatusNotFound, "Not found")
+			return
+		}
+
+		setETag(c, &model)
+		if header := c.GetHeader("If-None-Match"); header != "" && matchesETag(header, &model) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.JSON(http.StatusOK, res.view(&model))
 	}
 }
 
-func Create[T any](db *gorm.DB) gin.HandlerFunc {
+func Create[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := forTenant(c, db)
 		var model T
-		
-		if err := c.ShouldBindJSON(&model); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+		body, err := c.GetRawData()
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if err := res.decode(body, &model); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return createRow(c, tx, res, &model)
+		})
+		if err != nil {
+			respondWriteError(c, err)
+			return
+		}
+
+		setETag(c, &model)
+		c.JSON(http.StatusCreated, res.view(&model))
+	}
+}
+
+// Update replaces a row with the request body (PUT). Every writable field
+// must be given; nullable ones can be null.
+func Update[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
+	return update(res, db, true)
+}
+
+// Patch changes only the fields in the request body, a JSON Merge Patch
+// (PATCH). A null clears a nullable field such as Faculty.UserID.
+func Patch[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
+	return update(res, db, false)
+}
+
+func update[T any](res *Resource[T], db *gorm.DB, replace bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := forTenant(c, db)
+
+		body, err := c.GetRawData()
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
 			return
 		}
-		
-		if err := db.Create(&model).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		doc, err := decodeObject(body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, err.Error())
 			return
 		}
-		
-		c.JSON(http.StatusCreated, model)
+
+		var model *T
+		err = db.Transaction(func(tx *gorm.DB) error {
+			model, err = updateRow(c, tx, res, c.Param("id"), doc, replace, c.GetHeader("If-Match"))
+			return err
+		})
+		if err != nil {
+			respondWriteError(c, err)
+			return
+		}
+
+		setETag(c, model)
+		c.JSON(http.StatusOK, res.view(model))
 	}
 }
 
-func Update[T any](db *gorm.DB) gin.HandlerFunc {
+func Delete[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		var model T
-		
-		if err := db.First(&model, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		db := forTenant(c, db)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return deleteRow(c, tx, res, c.Param("id"), c.GetHeader("If-Match"))
+		})
+		if err != nil {
+			respondWriteError(c, err)
 			return
 		}
-		
-		if err := c.ShouldBindJSON(&model); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	}
+}
+
+// Trash lists the caller's soft-deleted rows, most recently deleted first
+func Trash[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := forTenant(c, db)
+		var rows []T
+
+		query, err := scopeToDepartment(c, db.Unscoped(), new(T))
+		if err != nil {
+			apierror.Abort(c, http.StatusUnauthorized, "User not found")
 			return
 		}
-		
-		if err := db.Save(&model).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		if err := query.Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&rows).Error; err != nil {
+			apierror.Respond(c, err)
 			return
 		}
-		
-		c.JSON(http.StatusOK, model)
+		c.JSON(http.StatusOK, res.viewAll(rows))
 	}
 }
 
-func Delete[T any](db *gorm.DB) gin.HandlerFunc {
+// Restore takes a row out of the trash, with the rows its delete cascaded to.
+// A row whose parents are still in the trash can't be restored.
+func Restore[T any](res *Resource[T], db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := forTenant(c, db)
 		id := c.Param("id")
-		
-		ptr := reflect.New(reflect.TypeOf((*T)(nil)).Elem()).Interface()
-		if err := db.Delete(ptr, id).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var model T
+
+		query, err := scopeToDepartment(c, db.Unscoped(), &model)
+		if err != nil {
+			apierror.Abort(c, http.StatusUnauthorized, "User not found")
 			return
 		}
-		
-		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+		if err := query.Where("deleted_at IS NOT NULL").First(&model, id).Error; err != nil {
+			apierror.Abort(c, http.StatusNotFound, "Not found in trash")
+			return
+		}
+
+		before, err := auditJSON(&model)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			resource, ids := resourceType(&model), []uint{modelID(&model)}
+			if err := checkTrashedParents(tx, resource, ids); err != nil {
+				return err
+			}
+			deletedAt := modelDeletedAt(&model)
+			if err := tx.Unscoped().Model(&model).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if err := restoreDependents(tx, resource, ids, deletedAt); err != nil {
+				return err
+			}
+			if err := tx.First(&model, id).Error; err != nil {
+				return err
+			}
+			return recordAudit(c, tx, models.AuditRestore, &model, before, &model)
+		})
+		if err != nil {
+			// e.g. a live row took its unique Code meanwhile
+			apierror.Respond(c, dbError(db, &model, err))
+			return
+		}
+
+		setETag(c, &model)
+		c.JSON(http.StatusOK, res.view(&model))
 	}
 }
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"tms-server/apierror"
+	"tms-server/config"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StartImpersonation switches the caller's session to another user of the
+// tenant, for support. The token names both users and expires after
+// IMPERSONATION_TTL, and every request made with it is recorded.
+func StartImpersonation(c *gin.Context) {
+	var input struct {
+		UserID uint   `json:"user_id" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	actor, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var target models.User
+	if err := tenantDB(c).First(&target, input.UserID).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if target.ID == actor.ID {
+		apierror.Abort(c, http.StatusBadRequest, "Cannot impersonate yourself")
+		return
+	}
+	if utils.HasPermission(target.TenantID, target.Role, models.PermImpersonate) {
+		apierror.Abort(c, http.StatusForbidden, "Cannot impersonate a user who can impersonate others")
+		return
+	}
+
+	impersonation := models.Impersonation{
+		ActorID:        actor.ID,
+		ActorUsername:  actor.Username,
+		TargetID:       target.ID,
+		TargetUsername: target.Username,
+		Reason:         input.Reason,
+		ExpiresAt:      time.Now().Add(config.GetEnvAsDuration("IMPERSONATION_TTL", "1h")),
+	}
+	if err := tenantDB(c).Create(&impersonation).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to start impersonation")
+		return
+	}
+
+	token, err := utils.GenerateImpersonationToken(&target, &impersonation)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	setAuthCookie(c, token)
+
+	log.Printf("User '%s' started impersonating '%s': %s", actor.Username, target.Username, input.Reason)
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Impersonation started",
+		"username":   target.Username,
+		"role":       target.Role,
+		"csrf_token": utils.CSRFToken(token),
+		"impersonation": gin.H{
+			"id":           impersonation.ID,
+			"impersonator": actor.Username,
+			"expires_at":   impersonation.ExpiresAt,
+		},
+	})
+}
+
+// StopImpersonation ends the current impersonation and logs the real user
+// back in as themselves
+func StopImpersonation(c *gin.Context) {
+	impersonationID := c.GetUint("impersonation_id")
+	if impersonationID == 0 {
+		apierror.Abort(c, http.StatusBadRequest, "Not impersonating")
+		return
+	}
+
+	err := tenantDB(c).Model(&models.Impersonation{}).
+		Where("id = ? AND ended_at IS NULL", impersonationID).
+		Update("ended_at", time.Now()).Error
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to stop impersonation")
+		return
+	}
+
+	var actor models.User
+	if err := tenantDB(c).First(&actor, c.GetUint("impersonator_id")).Error; err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	log.Printf("User '%s' stopped impersonating '%s'", actor.Username, c.GetString("username"))
+	issueSession(c, &actor, gin.H{"message": "Impersonation stopped"})
+}
+
+// GetCurrentImpersonation tells the frontend whether to show the
+// impersonation banner
+func GetCurrentImpersonation(c *gin.Context) {
+	c.JSON(http.StatusOK, impersonationBanner(c))
+}
+
+// impersonationBanner describes the impersonation the request is made under
+func impersonationBanner(c *gin.Context) gin.H {
+	if c.GetUint("impersonation_id") == 0 {
+		return gin.H{"impersonating": false}
+	}
+	return gin.H{
+		"impersonating":    true,
+		"impersonation_id": c.GetUint("impersonation_id"),
+		"impersonator":     c.GetString("impersonator"),
+	}
+}
+
+// GetImpersonations lists impersonations, newest first
+func GetImpersonations(c *gin.Context) {
+	query := tenantDB(c).Order("created_at DESC")
+
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor_username = ?", actor)
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target_username = ?", target)
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			apierror.Abort(c, http.StatusBadRequest, "Invalid 'limit' parameter. Must be a positive number.")
+			return
+		}
+		limit = min(l, 1000)
+	}
+
+	var impersonations []models.Impersonation
+	if err := query.Limit(limit).Find(&impersonations).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch impersonations")
+		return
+	}
+
+	c.JSON(http.StatusOK, impersonations)
+}
+
+// GetImpersonation returns an impersonation with every request made during it
+func GetImpersonation(c *gin.Context) {
+	var impersonation models.Impersonation
+	err := tenantDB(c).
+		Preload("Actions", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&impersonation, c.Param("id")).Error
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, impersonation)
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// preloadIncludes preloads the comma-separated associations in ?include=,
+// e.g. include=Batch.Course,Room, if the resource allows them
+func preloadIncludes(c *gin.Context, query *gorm.DB, allowed []string) (*gorm.DB, error) {
+	include := c.Query("include")
+	if include == "" {
+		return query, nil
+	}
+
+	for _, name := range strings.Split(include, ",") {
+		association, ok := findInclude(allowed, strings.TrimSpace(name))
+		if !ok {
+			if len(allowed) == 0 {
+				return nil, fmt.Errorf("cannot include '%s', this resource has no includes", name)
+			}
+			return nil, fmt.Errorf("cannot include '%s', allowed: %s", name, strings.Join(allowed, ", "))
+		}
+		query = query.Preload(association)
+	}
+	return query, nil
+}
+
+// findInclude matches a requested association regardless of case
+func findInclude(allowed []string, name string) (string, bool) {
+	for _, association := range allowed {
+		if strings.EqualFold(association, name) {
+			return association, true
+		}
+	}
+	return "", false
+}
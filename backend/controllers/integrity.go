@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dependentIDsShown caps the IDs listed per blocking relationship
+const dependentIDsShown = 20
+
+// blockingDependent is a set of rows that prevent a delete
+type blockingDependent struct {
+	Resource string `json:"resource"`
+	Column   string `json:"column"`
+	Count    int64  `json:"count"`
+	Trashed  int64  `json:"trashed"` // of Count, how many are in the trash
+	IDs      []uint `json:"ids"`
+}
+
+// blockingDependents finds rows whose restrict foreign key points at the rows
+// being deleted, including through rows the delete would cascade to. Trashed
+// rows block too: they still reference the rows until they are purged, and
+// could be restored under them.
+func blockingDependents(db *gorm.DB, resource string, ids []uint) ([]blockingDependent, error) {
+	var blocking []blockingDependent
+	for _, dep := range models.Dependencies {
+		if resourceType(dep.Parent) != resource {
+			continue
+		}
+
+		var depRows []struct {
+			ID      uint
+			Trashed bool
+		}
+		err := db.Unscoped().Model(dep.Model).Select("id, deleted_at IS NOT NULL AS trashed").
+			Where(dep.Column+" IN ?", ids).Order("id").Scan(&depRows).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(depRows) == 0 {
+			continue
+		}
+		depIDs := make([]uint, len(depRows))
+		var trashed int64
+		for i, row := range depRows {
+			depIDs[i] = row.ID
+			if row.Trashed {
+				trashed++
+			}
+		}
+
+		switch dep.OnDelete {
+		case models.OnDeleteRestrict:
+			blocking = append(blocking, blockingDependent{
+				Resource: resourceType(dep.Model),
+				Column:   dep.Column,
+				Count:    int64(len(depIDs)),
+				Trashed:  trashed,
+				IDs:      depIDs[:min(len(depIDs), dependentIDsShown)],
+			})
+		case models.OnDeleteCascade:
+			nested, err := blockingDependents(db, resourceType(dep.Model), depIDs)
+			if err != nil {
+				return nil, err
+			}
+			blocking = append(blocking, nested...)
+		}
+	}
+	return blocking, nil
+}
+
+// deleteDependents applies the delete policies to the live rows pointing at
+// the rows being deleted: cascades are soft-deleted in turn and set-null
+// references cleared. Restrict rules must have been checked beforehand.
+func deleteDependents(tx *gorm.DB, resource string, ids []uint) error {
+	for _, dep := range models.Dependencies {
+		if resourceType(dep.Parent) != resource {
+			continue
+		}
+
+		switch dep.OnDelete {
+		case models.OnDeleteCascade:
+			var depIDs []uint
+			if err := tx.Model(dep.Model).Where(dep.Column+" IN ?", ids).Pluck("id", &depIDs).Error; err != nil {
+				return err
+			}
+			if len(depIDs) == 0 {
+				continue
+			}
+			if err := deleteDependents(tx, resourceType(dep.Model), depIDs); err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", depIDs).Delete(newModel(dep.Model)).Error; err != nil {
+				return err
+			}
+		case models.OnDeleteSetNull:
+			if err := tx.Model(newModel(dep.Model)).Where(dep.Column+" IN ?", ids).Update(dep.Column, nil).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newModel returns a fresh instance of a model type, so statements that write
+// back into their model don't touch the shared instances in models.Dependencies
+func newModel(model any) any {
+	return reflect.New(reflect.TypeOf(model).Elem()).Interface()
+}
+
+// trashedParent is a parent in the trash that a row being restored points at
+type trashedParent struct {
+	Resource string `json:"resource"`
+	Column   string `json:"column"`
+	ID       uint   `json:"id"`
+}
+
+// trashedParents finds the trashed rows that the given rows point at, which
+// must be restored first
+func trashedParents(tx *gorm.DB, resource string, ids []uint) ([]trashedParent, error) {
+	var trashed []trashedParent
+	for _, dep := range models.Dependencies {
+		if resourceType(dep.Model) != resource {
+			continue
+		}
+
+		references := tx.Unscoped().Model(dep.Model).Select(dep.Column).Where("id IN ?", ids)
+		var parentIDs []uint
+		err := tx.Unscoped().Model(dep.Parent).Where("deleted_at IS NOT NULL AND id IN (?)", references).
+			Order("id").Pluck("id", &parentIDs).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range parentIDs {
+			trashed = append(trashed, trashedParent{Resource: resourceType(dep.Parent), Column: dep.Column, ID: id})
+		}
+	}
+	return trashed, nil
+}
+
+// restoreDependents takes the rows that deleting the given rows cascaded to
+// out of the trash, recognised by sharing their deleted_at. Rows deleted on
+// their own, before or after, stay in the trash.
+func restoreDependents(tx *gorm.DB, resource string, ids []uint, deletedAt time.Time) error {
+	for _, dep := range models.Dependencies {
+		if resourceType(dep.Parent) != resource || dep.OnDelete != models.OnDeleteCascade {
+			continue
+		}
+
+		var depIDs []uint
+		err := tx.Unscoped().Model(dep.Model).Where(dep.Column+" IN ? AND deleted_at = ?", ids, deletedAt).
+			Pluck("id", &depIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(depIDs) == 0 {
+			continue
+		}
+		if err := tx.Unscoped().Model(newModel(dep.Model)).Where("id IN ?", depIDs).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := checkTrashedParents(tx, resourceType(dep.Model), depIDs); err != nil {
+			return err
+		}
+		if err := restoreDependents(tx, resourceType(dep.Model), depIDs, deletedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTrashedParents refuses to restore rows whose parents are in the trash
+func checkTrashedParents(tx *gorm.DB, resource string, ids []uint) error {
+	trashed, err := trashedParents(tx, resource, ids)
+	if err != nil {
+		return err
+	}
+	if len(trashed) == 0 {
+		return nil
+	}
+	return &apierror.Error{
+		Status:  http.StatusConflict,
+		Code:    apierror.CodeParentTrashed,
+		Message: "Cannot restore, resources it depends on are in the trash",
+		Details: gin.H{"parents": trashed},
+	}
+}
+
+// modelDeletedAt is when a trashed row was deleted
+func modelDeletedAt(model any) time.Time {
+	return reflect.Indirect(reflect.ValueOf(model)).FieldByName("DeletedAt").Interface().(gorm.DeletedAt).Time
+}
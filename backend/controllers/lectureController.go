@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"cmp"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"tms-server/apierror"
 	"tms-server/models"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +26,12 @@ func QueryLectures(db *gorm.DB) gin.HandlerFunc {
 		roomIDStr := c.Query("room_id")
 		batchIDStr := c.Query("batch_id")
 
-		query := db.Preload("Batch").Preload("Subject").Preload("Faculty").Preload("Room").
+		query, err := scopeToDepartment(c, forTenant(c, db), &models.Lecture{})
+		if err != nil {
+			apierror.Abort(c, http.StatusUnauthorized, "User not found")
+			return
+		}
+		query = query.Preload("Batch").Preload("Subject").Preload("Faculty").Preload("Room").
 			Joins("JOIN batches ON batches.id = lectures.batch_id")
 
 		if batchIDStr != "" {
@@ -53,7 +62,7 @@ func QueryLectures(db *gorm.DB) gin.HandlerFunc {
 			if year, err := strconv.Atoi(yearStr); err == nil {
 				query = query.Where("batches.year = ?", year)
 			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year parameter"})
+				apierror.Abort(c, http.StatusBadRequest, "Invalid year parameter")
 				return
 			}
 		}
@@ -62,7 +71,7 @@ func QueryLectures(db *gorm.DB) gin.HandlerFunc {
 			if courseID, err := strconv.Atoi(courseIDStr); err == nil {
 				query = query.Where("batches.course_id = ?", courseID)
 			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course_id parameter"})
+				apierror.Abort(c, http.StatusBadRequest, "Invalid course_id parameter")
 				return
 			}
 		}
@@ -72,10 +81,59 @@ func QueryLectures(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&lectures).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
 		c.JSON(http.StatusOK, lectures)
 	}
 }
+
+// LintLectures checks existing lectures against the rules spanning other
+// rows and reports every violation, errors first. Filters: batch_id,
+// semester, severity.
+func LintLectures(c *gin.Context) {
+	query, err := scopeToDepartment(c, tenantDB(c).Model(&models.Lecture{}), &models.Lecture{})
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("lectures.batch_id = ?", batchID)
+	}
+	if semester := c.Query("semester"); semester != "" {
+		query = query.Where("lectures.semester = ?", semester)
+	}
+
+	severity := c.Query("severity")
+	if severity != "" && severity != models.SeverityError && severity != models.SeverityWarning {
+		apierror.Abort(c, http.StatusBadRequest, "severity must be 'error' or 'warning'")
+		return
+	}
+
+	violations, err := models.LintLectures(query, severity)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+
+	summary := map[string]int{models.SeverityError: 0, models.SeverityWarning: 0}
+	for _, v := range violations {
+		summary[v.Severity]++
+	}
+	slices.SortStableFunc(violations, func(a, b models.Violation) int {
+		if a.Severity != b.Severity {
+			// error sorts before warning
+			return strings.Compare(a.Severity, b.Severity)
+		}
+		return cmp.Compare(a.LectureID, b.LectureID)
+	})
+	if violations == nil {
+		violations = []models.Violation{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":    summary,
+		"violations": violations,
+	})
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"tms-server/apierror"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPerPage = 1000
+	maxPerPage     = 1000
+)
+
+// page is the slice of a list a request asked for
+type page struct {
+	Number  int
+	PerPage int
+}
+
+// listSpec fills in the defaults of a resource's ListSpec
+func listSpec(spec models.ListSpec) models.ListSpec {
+	if len(spec.Sort) == 0 {
+		spec.Sort = []string{"id"}
+	}
+	if spec.Default == "" {
+		spec.Default = "id"
+	}
+	if spec.PerPage == 0 {
+		spec.PerPage = defaultPerPage
+	}
+	return spec
+}
+
+// filterList applies the equality and range filters in the query string
+func filterList(c *gin.Context, query *gorm.DB, spec models.ListSpec) *gorm.DB {
+	for _, column := range spec.Equal {
+		if value, ok := c.GetQuery(column); ok {
+			query = query.Where(column+" IN ?", strings.Split(value, ","))
+		}
+	}
+	for _, column := range spec.Range {
+		if from := c.Query(column + "_from"); from != "" {
+			query = query.Where(column+" >= ?", from)
+		}
+		if to := c.Query(column + "_to"); to != "" {
+			query = query.Where(column+" <= ?", to)
+		}
+	}
+	return query
+}
+
+// sortList orders by the comma-separated sort parameter, e.g. sort=-date,id
+func sortList(c *gin.Context, query *gorm.DB, spec models.ListSpec) (*gorm.DB, error) {
+	sort := c.DefaultQuery("sort", spec.Default)
+	for _, field := range strings.Split(sort, ",") {
+		column, desc := strings.CutPrefix(strings.TrimSpace(field), "-")
+		if !slices.Contains(spec.Sort, column) && column != "id" {
+			return nil, fmt.Errorf("cannot sort by '%s', allowed: %s", column, strings.Join(spec.Sort, ", "))
+		}
+		if desc {
+			column += " DESC"
+		}
+		query = query.Order(column)
+	}
+	// Keep pages stable when the sort column has ties
+	return query.Order("id"), nil
+}
+
+// pageOf reads page (from 1) and per_page from the query string
+func pageOf(c *gin.Context, spec models.ListSpec) (page, error) {
+	p := page{Number: 1, PerPage: spec.PerPage}
+	if s := c.Query("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return p, errors.New("Invalid 'page' parameter. Must be a positive number.")
+		}
+		p.Number = n
+	}
+	if s := c.Query("per_page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return p, errors.New("Invalid 'per_page' parameter. Must be a positive number.")
+		}
+		p.PerPage = min(n, maxPerPage)
+	}
+	return p, nil
+}
+
+func (p page) apply(query *gorm.DB) *gorm.DB {
+	return query.Offset((p.Number - 1) * p.PerPage).Limit(p.PerPage)
+}
+
+// setPageHeaders reports the pagination in headers, leaving the body a plain
+// array as before pagination existed
+func setPageHeaders(c *gin.Context, p page, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.Header("X-Page", strconv.Itoa(p.Number))
+	c.Header("X-Per-Page", strconv.Itoa(p.PerPage))
+
+	var links []string
+	if p.Number > 1 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(c, p.Number-1)))
+	}
+	if int64(p.Number*p.PerPage) < total {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(c, p.Number+1)))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
+
+func pageURL(c *gin.Context, number int) string {
+	u := *c.Request.URL
+	q := u.Query()
+	q.Set("page", strconv.Itoa(number))
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// isInvalidValue reports whether the database rejected a filter value, e.g.
+// a word compared with a number column
+func isInvalidValue(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "22")
+}
+
+// listError answers a failed list query
+func listError(c *gin.Context, err error) {
+	if isInvalidValue(err) {
+		apierror.Respond(c, &apierror.Error{
+			Status:  http.StatusBadRequest,
+			Code:    apierror.CodeInvalidValue,
+			Message: "Invalid filter value",
+		})
+		return
+	}
+	apierror.Respond(c, err)
+}
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateExpiry = 10 * time.Minute
+)
+
+const (
+	oidcModeLogin = "login"
+	oidcModeLink  = "link"
+
+	// The role of auto-provisioned users, and the only one whose accounts are
+	// linked by email without the user asking
+	oidcProvisionRole = "faculty"
+)
+
+var (
+	errOIDCUserNotAllowed = errors.New("no account is linked to this identity")
+	errOIDCLinkRequired   = errors.New("sign in with your password and link this identity from your account first")
+	errOIDCAlreadyLinked  = errors.New("this identity is already linked to another account")
+)
+
+// OIDCLogin redirects the browser to the identity provider
+func OIDCLogin(c *gin.Context) {
+	startOIDC(c, oidcModeLogin)
+}
+
+// OIDCLink lets a logged-in user link their account to an identity at the
+// provider, for accounts that aren't linked automatically by email
+func OIDCLink(c *gin.Context) {
+	startOIDC(c, oidcModeLink)
+}
+
+func startOIDC(c *gin.Context, mode string) {
+	client, err := utils.OIDC(c.Request.Context())
+	if errors.Is(err, utils.ErrOIDCNotConfigured) {
+		apierror.Abort(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		apierror.Abort(c, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+
+	state, err1 := utils.RandomString(16)
+	nonce, err2 := utils.RandomString(16)
+	if err1 != nil || err2 != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "Could not start login")
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	// The IdP redirects back with a top-level GET, so Lax is enough here
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    strings.Join([]string{state, nonce, verifier, mode}, "."),
+		Path:     "/",
+		Expires:  time.Now().Add(oidcStateExpiry),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := client.OAuth2.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback handles the redirect back from the identity provider, maps the
+// identity to a user and issues the usual auth cookie
+func OIDCCallback(c *gin.Context) {
+	client, err := utils.OIDC(c.Request.Context())
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, utils.ErrOIDCNotConfigured.Error())
+		return
+	}
+
+	if errParam := c.Query("error"); errParam != "" {
+		apierror.Abort(c, http.StatusUnauthorized, "Identity provider returned an error: "+errParam)
+		return
+	}
+
+	stateCookie, err := c.Cookie(oidcStateCookie)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	parts := strings.Split(stateCookie, ".")
+	if err != nil || len(parts) != 4 || parts[0] != c.Query("state") {
+		apierror.Abort(c, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+	nonce, verifier, mode := parts[1], parts[2], parts[3]
+
+	identity, err := client.Exchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		apierror.Abort(c, http.StatusUnauthorized, "Could not verify identity")
+		return
+	}
+
+	if mode == oidcModeLink {
+		linkOIDCIdentity(c, client, identity)
+		return
+	}
+
+	user, err := oidcUser(tenantDB(c), client, identity)
+	if errors.Is(err, errOIDCUserNotAllowed) || errors.Is(err, errOIDCLinkRequired) {
+		recordLoginAttempt(c, identity.Email, c.ClientIP(), false, "oidc_no_account")
+		apierror.Abort(c, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, utils.ErrOIDCUsernameTaken) {
+		recordLoginAttempt(c, identity.Email, c.ClientIP(), false, "oidc_username_taken")
+		apierror.Abort(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC user mapping failed for '%s': %v", identity.Email, err)
+		apierror.Abort(c, http.StatusInternalServerError, "Could not sign in")
+		return
+	}
+
+	// The provider stands in for the password only; TOTP is still ours to check
+	if user.TOTPEnabled || utils.TOTPRequired(user.Role) {
+		if client.FrontendRedirectURL != "" {
+			redirectSecondFactor(c, client.FrontendRedirectURL, user)
+			return
+		}
+		requireSecondFactor(c, user)
+		return
+	}
+
+	recordLoginAttempt(c, user.Username, c.ClientIP(), true, "oidc")
+
+	token, err := utils.GenerateToken(user)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	setAuthCookie(c, token)
+
+	if client.FrontendRedirectURL != "" {
+		c.Redirect(http.StatusFound, client.FrontendRedirectURL)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Login Successful",
+		"username":   user.Username,
+		"role":       user.Role,
+		"csrf_token": utils.CSRFToken(token),
+	})
+}
+
+// redirectSecondFactor sends the browser to the frontend with an MFA token
+// to finish the login through /login/totp. The token goes in the fragment so
+// it never reaches a server log.
+func redirectSecondFactor(c *gin.Context, frontend string, user *models.User) {
+	mfaToken, err := utils.GenerateMFAToken(user)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	fragment := url.Values{
+		"mfa_token":           {mfaToken},
+		"enrollment_required": {strconv.FormatBool(!user.TOTPEnabled)},
+	}
+	c.Redirect(http.StatusFound, frontend+"#"+fragment.Encode())
+}
+
+// linkOIDCIdentity links the identity to the user whose session started the
+// link, checked again here as the callback itself is public
+func linkOIDCIdentity(c *gin.Context, client *utils.OIDCClient, identity *utils.OIDCIdentity) {
+	token, _ := c.Cookie("auth_token")
+	claims, err := utils.ValidateToken(token)
+	if err != nil || claims.ImpersonationID != 0 {
+		apierror.Abort(c, http.StatusUnauthorized, "Sign in before linking an identity")
+		return
+	}
+	if tenantID := claims.TenantID; tenantID != c.GetUint("tenant_id") && !(tenantID == 0 && c.GetUint("tenant_id") == models.DefaultTenantID) {
+		apierror.Abort(c, http.StatusForbidden, "Token belongs to a different tenant")
+		return
+	}
+
+	db := tenantDB(c)
+	externalID := identity.ExternalID()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, claims.UserID).Error; err != nil {
+			return err
+		}
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("external_id = ? AND id <> ?", externalID, user.ID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return errOIDCAlreadyLinked
+		}
+		log.Printf("User '%s' linked OIDC identity %s", user.Username, externalID)
+		return tx.Model(&user).Update("external_id", externalID).Error
+	})
+	if errors.Is(err, errOIDCAlreadyLinked) {
+		apierror.Abort(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	if client.FrontendRedirectURL != "" {
+		c.Redirect(http.StatusFound, client.FrontendRedirectURL)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Identity linked"})
+}
+
+// oidcUser finds the user linked to the identity, links an existing faculty
+// user with the same verified email on first login, or auto-provisions a
+// faculty user when the email domain allows it. Other accounts with the email
+// must link the identity themselves, as the provider's word on an email is
+// not enough to take over an admin or a TOTP-protected account.
+func oidcUser(db *gorm.DB, client *utils.OIDCClient, identity *utils.OIDCIdentity) (*models.User, error) {
+	externalID := identity.ExternalID()
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("external_id = ?", externalID).First(&user).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if identity.Email == "" || !identity.EmailVerified {
+			return errOIDCUserNotAllowed
+		}
+
+		err = tx.Where("LOWER(email) = ?", identity.Email).First(&user).Error
+		if err == nil {
+			if !canAutoLink(&user) {
+				return errOIDCLinkRequired
+			}
+			log.Printf("Linking user '%s' to OIDC identity %s", user.Username, externalID)
+			return tx.Model(&user).Update("external_id", externalID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if !client.CanProvision(identity.Email) {
+			return errOIDCUserNotAllowed
+		}
+
+		provisioned, err := client.NewUser(identity, oidcProvisionRole, func(username string) (bool, error) {
+			var count int64
+			err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+			return count > 0, err
+		})
+		if err != nil {
+			return err
+		}
+		user = *provisioned
+		log.Printf("Auto-provisioning faculty user '%s' from OIDC", user.Username)
+		err = tx.Create(&user).Error
+		// Lost a race with another insert of the same username
+		if dbErr := apierror.FromDB(err, nil); dbErr != nil && dbErr.Code == apierror.CodeDuplicate {
+			return utils.ErrOIDCUsernameTaken
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// canAutoLink reports whether an account may be linked to an identity just
+// because their emails match
+func canAutoLink(user *models.User) bool {
+	return user.Role == oidcProvisionRole && !user.TOTPEnabled && !utils.TOTPRequired(user.Role)
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"testing"
+	"tms-server/models"
+)
+
+func TestCanAutoLink(t *testing.T) {
+	t.Setenv("TOTP_REQUIRED_ROLES", "admin")
+
+	tests := []struct {
+		name string
+		user models.User
+		want bool
+	}{
+		{"faculty", models.User{Role: "faculty"}, true},
+		{"faculty with TOTP", models.User{Role: "faculty", TOTPEnabled: true}, false},
+		{"admin", models.User{Role: "admin"}, false},
+		{"superadmin", models.User{Role: "superadmin"}, false},
+	}
+	for _, tt := range tests {
+		if got := canAutoLink(&tt.user); got != tt.want {
+			t.Errorf("%s: canAutoLink = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	t.Setenv("TOTP_REQUIRED_ROLES", "faculty")
+	if canAutoLink(&models.User{Role: "faculty"}) {
+		t.Error("faculty who must use TOTP: canAutoLink = true, want false")
+	}
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"tms-server/apierror"
+	"tms-server/config"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// ChangePassword lets the logged-in user change their own password
+func ChangePassword(c *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := utils.ValidatePassword(input.NewPassword); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if !utils.CheckPassword(user.Password, input.CurrentPassword) {
+		apierror.Abort(c, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+
+	if err := setPassword(tenantDB(c), user, input.NewPassword); err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to update password")
+		return
+	}
+
+	log.Printf("User '%s' changed their password", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// CreateResetToken issues a one-time password reset token for a user
+func CreateResetToken(c *gin.Context) {
+	var user models.User
+	if err := tenantDB(c).First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	token, record, err := issueToken(models.TokenPurposeReset, c.GetString("username"),
+		config.GetEnvAsDuration("PASSWORD_RESET_TTL", "24h"))
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to create reset token")
+		return
+	}
+	record.UserID = &user.ID
+
+	if err := tenantDB(c).Create(record).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to create reset token")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"username":   user.Username,
+		"expires_at": record.ExpiresAt,
+	})
+}
+
+// ResetPassword sets a new password using a reset token
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := utils.ValidatePassword(input.NewPassword); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		record, err := consumeToken(tx, input.Token, models.TokenPurposeReset)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if record.UserID == nil || tx.First(&user, *record.UserID).Error != nil {
+			return errInvalidToken
+		}
+		return setPassword(tx, &user, input.NewPassword)
+	})
+	if errors.Is(err, errInvalidToken) {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset"})
+}
+
+// CreateInvitation issues an invitation token for a faculty without an account
+func CreateInvitation(c *gin.Context) {
+	var input struct {
+		FacultyID uint `json:"faculty_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var faculty models.Faculty
+	if err := tenantDB(c).First(&faculty, input.FacultyID).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Faculty not found")
+		return
+	}
+	if faculty.UserID != nil {
+		apierror.Abort(c, http.StatusConflict, "Faculty is already linked to a user")
+		return
+	}
+
+	token, record, err := issueToken(models.TokenPurposeInvite, c.GetString("username"),
+		config.GetEnvAsDuration("INVITATION_TTL", "168h"))
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to create invitation")
+		return
+	}
+	record.FacultyID = &faculty.ID
+
+	if err := tenantDB(c).Create(record).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to create invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"faculty":    faculty.Name,
+		"expires_at": record.ExpiresAt,
+	})
+}
+
+// AcceptInvitation creates a faculty user from an invitation token and links
+// it to the invited faculty record
+func AcceptInvitation(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := utils.ValidatePassword(input.Password); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	errUsernameTaken := errors.New("username is already taken")
+	var user models.User
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		record, err := consumeToken(tx, input.Token, models.TokenPurposeInvite)
+		if err != nil {
+			return err
+		}
+
+		var faculty models.Faculty
+		if record.FacultyID == nil || tx.First(&faculty, *record.FacultyID).Error != nil || faculty.UserID != nil {
+			return errInvalidToken
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", input.Username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errUsernameTaken
+		}
+
+		hash, err := utils.HashPassword(input.Password)
+		if err != nil {
+			return err
+		}
+		user = models.User{Username: input.Username, Password: hash, Role: "faculty"}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Model(&faculty).Update("user_id", user.ID).Error
+	})
+	switch {
+	case errors.Is(err, errInvalidToken):
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, errUsernameTaken):
+		apierror.Abort(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		apierror.Abort(c, http.StatusInternalServerError, "failed to accept invitation")
+		return
+	}
+
+	log.Printf("Invitation accepted, created faculty user '%s'", user.Username)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Account created",
+		"username": user.Username,
+		"role":     user.Role,
+	})
+}
+
+func issueToken(purpose, createdBy string, ttl time.Duration) (string, *models.UserToken, error) {
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &models.UserToken{
+		TokenHash: hash,
+		Purpose:   purpose,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// consumeToken looks up an unused, unexpired token and marks it as used
+func consumeToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	var record models.UserToken
+	err := tx.Where("token_hash = ? AND purpose = ?", utils.HashOpaqueToken(token), purpose).First(&record).Error
+	if err != nil || !record.Usable() {
+		return nil, errInvalidToken
+	}
+
+	now := time.Now()
+	result := tx.Model(&record).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidToken
+	}
+	return &record, nil
+}
+
+func setPassword(tx *gorm.DB, user *models.User, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return tx.Model(user).Update("password", hash).Error
+}
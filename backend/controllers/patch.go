@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// writableFields returns the columns of a model a client can set: everything
+// but the primary key, the tenant, deletion, the version and fields hidden
+// from JSON
+func writableFields(s *schema.Schema) []*schema.Field {
+	var fields []*schema.Field
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.DBName == "tenant_id" || field.DBName == "deleted_at" || field.DBName == "version" {
+			continue
+		}
+		if jsonName(field) == "-" {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// jsonName is the key encoding/json uses for a field
+func jsonName(field *schema.Field) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// decodeObject reads a request body that must be a JSON object
+func decodeObject(body []byte) (map[string]json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return nil, errors.New("Body must be a JSON object")
+	}
+	return doc, nil
+}
+
+// applyFields writes the fields present in doc onto model, matching keys
+// regardless of case like encoding/json. Only the resource's input fields
+// are writable, or every writable field if input is nil. A null clears a
+// nullable field. With replace set every writable field must be present, as
+// PUT replaces the row.
+//
+// ID may be sent if it matches the row, and read-only keys such as nested
+// associations are ignored, so a row fetched with GET can be sent back as is.
+// It returns the fields to save.
+func applyFields(db *gorm.DB, model any, input any, doc map[string]json.RawMessage, replace bool) ([]*schema.Field, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	fields := inputFields(stmt.Schema, input)
+
+	value := reflect.ValueOf(model).Elem()
+	seen := make(map[*schema.Field]bool)
+	var applied []*schema.Field
+	var missing []string
+
+	for key, raw := range doc {
+		field := findField(fields, key)
+		if field == nil {
+			if strings.EqualFold(key, "ID") {
+				var id uint
+				if json.Unmarshal(raw, &id) != nil || id != modelID(model) {
+					return nil, errors.New("ID can't be changed")
+				}
+				continue
+			}
+			if readOnlyKey(stmt.Schema, key) {
+				continue
+			}
+			return nil, fmt.Errorf("Unknown field '%s'", key)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("Field '%s' given more than once", jsonName(field))
+		}
+		seen[field] = true
+
+		target := value.FieldByIndex(field.StructField.Index)
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			switch target.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+				target.SetZero()
+			default:
+				return nil, fmt.Errorf("Field '%s' can't be null", jsonName(field))
+			}
+		} else if err := json.Unmarshal(raw, target.Addr().Interface()); err != nil {
+			return nil, fmt.Errorf("Invalid value for '%s'", jsonName(field))
+		}
+		applied = append(applied, field)
+	}
+
+	if replace {
+		for _, field := range fields {
+			if !seen[field] {
+				missing = append(missing, jsonName(field))
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("PUT replaces the whole resource, missing: %s (use PATCH to change some fields)", strings.Join(missing, ", "))
+		}
+	}
+	return applied, nil
+}
+
+func findField(fields []*schema.Field, key string) *schema.Field {
+	for _, field := range fields {
+		if strings.EqualFold(jsonName(field), key) {
+			return field
+		}
+	}
+	return nil
+}
+
+// readOnlyKey reports whether key names an association or a column clients
+// can't set
+func readOnlyKey(s *schema.Schema, key string) bool {
+	for name := range s.Relationships.Relations {
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	for _, field := range s.Fields {
+		if strings.EqualFold(jsonName(field), key) {
+			return true
+		}
+	}
+	return false
+}
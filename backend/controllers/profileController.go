@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// attentionLimit caps how many unmarked past sessions /me returns
+const attentionLimit = 50
+
+// GetMe returns the logged-in user with everything a dashboard needs: the
+// linked faculty and their subjects, today's and the coming week's sessions,
+// and past sessions that were never marked held or cancelled
+func GetMe(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	response := gin.H{
+		"user": gin.H{
+			"id":            user.ID,
+			"username":      user.Username,
+			"role":          user.Role,
+			"email":         user.Email,
+			"department_id": user.DepartmentID,
+			"totp_enabled":  user.TOTPEnabled,
+		},
+		"impersonation": impersonationBanner(c),
+		"faculty":       nil,
+	}
+
+	var faculty models.Faculty
+	err = tenantDB(c).Preload("Subjects.Course").Where("user_id = ?", user.ID).First(&faculty).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Not everyone teaches, e.g. superadmins
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch faculty")
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekEnd := today.AddDate(0, 0, 7)
+
+	var todays, upcoming, unmarked []models.Session
+	var unmarkedCount int64
+
+	sessions := func() *gorm.DB {
+		return ownSessions(tenantDB(c).Model(&models.Session{}), faculty.ID)
+	}
+	err = withSessionDetails(sessions()).
+		Where("sessions.date = ?", today).
+		Order("lectures.start_time").
+		Find(&todays).Error
+	if err == nil {
+		err = withSessionDetails(sessions()).
+			Where("sessions.date > ? AND sessions.date <= ?", today, weekEnd).
+			Order("sessions.date, lectures.start_time").
+			Find(&upcoming).Error
+	}
+	if err == nil {
+		err = sessions().
+			Where("sessions.date < ? AND COALESCE(sessions.status, '') = ''", today).
+			Count(&unmarkedCount).Error
+	}
+	if err == nil {
+		err = withSessionDetails(sessions()).
+			Where("sessions.date < ? AND COALESCE(sessions.status, '') = ''", today).
+			Order("sessions.date DESC, lectures.start_time").
+			Limit(attentionLimit).
+			Find(&unmarked).Error
+	}
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch sessions")
+		return
+	}
+
+	response["faculty"] = faculty
+	response["today"] = todays
+	response["upcoming"] = upcoming
+	response["needs_attention"] = gin.H{
+		"total":    unmarkedCount,
+		"sessions": unmarked,
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Resource declares a model served by the generic handlers. Everything
+// about it that isn't in the model's struct tags is declared here, once;
+// its routes, its section of the API reference and its checks are
+// derived from the declaration.
+type Resource[T any] struct {
+	Name  string   // path segment and audit resource type, e.g. course
+	Read  []string // any of these permissions may list and get rows
+	Write []string // any of these may create, change, delete and restore rows
+
+	List models.ListSpec // columns the list can be sorted and filtered by
+
+	// Associations ?include= may preload. Nested ones are listed in full,
+	// e.g. Batch.Course; including one also loads its parents.
+	Includes []string
+
+	// Check finds conflicts of a written row with other rows. It runs after
+	// the write in the same transaction, and any violation of severity
+	// error rolls the write back with 422.
+	Check func(row *T, tx *gorm.DB) ([]models.Violation, error)
+
+	// Input lists the fields clients may write, as a struct whose fields
+	// are writable fields of the model, e.g. models.UserInput. Defaults to
+	// every writable field.
+	Input any
+
+	// View shapes a row for responses, e.g. to leave out secrets. It must
+	// keep the ID and Version fields. Defaults to the row as it is.
+	View func(row *T) any
+
+	// Extra endpoints of the resource, e.g. GET /lecture/lint. One with the
+	// same method and path as a generated endpoint replaces it.
+	Extra []Route
+}
+
+// Route is one endpoint of a resource
+type Route struct {
+	Method      string
+	Path        string
+	Permissions []string // any of them allows the request
+	Summary     string
+	Handler     gin.HandlerFunc
+}
+
+// Registered is a Resource of any model, as the routes and docs see it
+type Registered interface {
+	Spec() Spec
+	Routes(db *gorm.DB) []Route
+	Verify() error
+}
+
+// Spec is the untyped part of a Resource
+type Spec struct {
+	Name     string
+	Model    any
+	Read     []string
+	Write    []string
+	List     models.ListSpec
+	Includes []string
+	Input    any
+	Checked  bool
+	Viewed   bool
+}
+
+func (res *Resource[T]) Spec() Spec {
+	return Spec{
+		Name:     res.Name,
+		Model:    new(T),
+		Read:     res.Read,
+		Write:    res.Write,
+		List:     listSpec(res.List),
+		Includes: res.Includes,
+		Input:    res.Input,
+		Checked:  res.Check != nil,
+		Viewed:   res.View != nil,
+	}
+}
+
+// Routes lists every endpoint of the resource with handlers on db
+func (res *Resource[T]) Routes(db *gorm.DB) []Route {
+	base, item := "/"+res.Name, "/"+res.Name+"/:id"
+	routes := []Route{
+		{http.MethodGet, base, res.Read, "List, paginated and filtered", All(res, db)},
+		{http.MethodGet, item, res.Read, "Get one", Get(res, db)},
+		{http.MethodPost, base, res.Write, "Create", Create(res, db)},
+		{http.MethodPut, item, res.Write, "Replace, every field required", Update(res, db)},
+		{http.MethodPatch, item, res.Write, "Change some fields (JSON Merge Patch)", Patch(res, db)},
+		{http.MethodDelete, item, res.Write, "Delete (to the trash)", Delete(res, db)},
+		{http.MethodPost, base + "/bulk", res.Write, "Create many", BulkCreate(res, db)},
+		{http.MethodPatch, base + "/bulk", res.Write, "Change many", BulkPatch(res, db)},
+		{http.MethodDelete, base + "/bulk", res.Write, "Delete many", BulkDelete(res, db)},
+		{http.MethodGet, base + "/trash", res.Write, "List deleted rows", Trash(res, db)},
+		{http.MethodPost, item + "/restore", res.Write, "Restore from the trash", Restore(res, db)},
+	}
+
+	for _, extra := range res.Extra {
+		replaced := false
+		for i, route := range routes {
+			if route.Method == extra.Method && route.Path == extra.Path {
+				routes[i], replaced = extra, true
+			}
+		}
+		if !replaced {
+			routes = append(routes, extra)
+		}
+	}
+	return routes
+}
+
+// Verify checks the declaration against the model and the rest of the
+// server. The routes tests run it on every resource, so a mistake fails the
+// build rather than surfacing as a 500 or a route nobody can reach.
+func (res *Resource[T]) Verify() error {
+	sch, err := modelSchema(new(T))
+	if err != nil {
+		return err
+	}
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("resource %s: %s", res.Name, fmt.Sprintf(format, args...))
+	}
+
+	// Audit logs and integrity errors name resources by their model
+	if want := resourceType(new(T)); res.Name != want {
+		return fail("name must be %s, the model's type name", want)
+	}
+	if !slices.ContainsFunc(models.Trashable, func(model any) bool {
+		return reflect.TypeOf(model) == reflect.TypeOf(new(T))
+	}) {
+		return fail("model is missing from models.Trashable")
+	}
+	if len(res.Read) == 0 || len(res.Write) == 0 {
+		return fail("read and write permissions are required")
+	}
+
+	spec := listSpec(res.List)
+	columns := slices.Concat(spec.Sort, spec.Equal, spec.Range, []string{strings.TrimPrefix(spec.Default, "-")})
+	for _, column := range columns {
+		if field := sch.LookUpField(column); field == nil || field.DBName != column {
+			return fail("list column %s is not a column of %s", column, sch.Table)
+		}
+	}
+	for _, include := range res.Includes {
+		if !hasRelation(sch, include) {
+			return fail("include %s is not an association of %s", include, sch.Name)
+		}
+	}
+	if res.Input != nil {
+		input := reflect.TypeOf(res.Input)
+		if input.Kind() != reflect.Struct {
+			return fail("input must be a struct")
+		}
+		writable := writableFields(sch)
+		for i := range input.NumField() {
+			field := input.Field(i)
+			j := slices.IndexFunc(writable, func(f *schema.Field) bool { return f.Name == field.Name })
+			if j < 0 || writable[j].FieldType != field.Type || writable[j].Tag.Get("json") != field.Tag.Get("json") {
+				return fail("input field %s is not a writable field of %s", field.Name, sch.Name)
+			}
+		}
+	}
+	if view := reflect.Indirect(reflect.ValueOf(res.view(new(T)))); view.Kind() != reflect.Struct || !view.FieldByName("Version").IsValid() {
+		return fail("view must keep the Version field for ETags")
+	}
+
+	seen := map[string]bool{}
+	for _, route := range res.Routes(nil) {
+		key := route.Method + " " + route.Path
+		if seen[key] {
+			return fail("%s is declared twice", key)
+		}
+		seen[key] = true
+		if route.Path != "/"+res.Name && !strings.HasPrefix(route.Path, "/"+res.Name+"/") {
+			return fail("%s is outside /%s", key, res.Name)
+		}
+		if len(route.Permissions) == 0 || route.Handler == nil {
+			return fail("%s needs permissions and a handler", key)
+		}
+		for _, permission := range route.Permissions {
+			if !slices.ContainsFunc(models.AllPermissions, func(p models.Permission) bool { return p.Name == permission }) {
+				return fail("%s requires %s, which is not in models.AllPermissions", key, permission)
+			}
+		}
+	}
+	return nil
+}
+
+// modelSchema parses a model without a database connection, as the naming
+// strategy is the default one
+func modelSchema(model any) (*schema.Schema, error) {
+	return schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+}
+
+// hasRelation reports whether a dotted association path, e.g.
+// Batch.Course, can be preloaded from the schema
+func hasRelation(sch *schema.Schema, path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		rel, ok := sch.Relationships.Relations[name]
+		if !ok {
+			return false
+		}
+		sch = rel.FieldSchema
+	}
+	return true
+}
+
+// inputFields returns the writable fields of a model that input lets clients
+// set, or all of them without an input
+func inputFields(sch *schema.Schema, input any) []*schema.Field {
+	fields := writableFields(sch)
+	if input == nil {
+		return fields
+	}
+	inputType := reflect.TypeOf(input)
+	return slices.DeleteFunc(fields, func(field *schema.Field) bool {
+		_, ok := inputType.FieldByName(field.Name)
+		return !ok
+	})
+}
+
+// decode reads a new row from a request body, through the resource's input
+// if it has one so fields outside it keep their zero values
+func (res *Resource[T]) decode(data []byte, row *T) error {
+	if res.Input == nil {
+		return json.Unmarshal(data, row)
+	}
+	input := reflect.New(reflect.TypeOf(res.Input)).Elem()
+	if err := json.Unmarshal(data, input.Addr().Interface()); err != nil {
+		return err
+	}
+	value := reflect.ValueOf(row).Elem()
+	for i := range input.NumField() {
+		value.FieldByName(input.Type().Field(i).Name).Set(input.Field(i))
+	}
+	return nil
+}
+
+// view is a row as responses show it
+func (res *Resource[T]) view(row *T) any {
+	if res.View == nil {
+		return row
+	}
+	return res.View(row)
+}
+
+func (res *Resource[T]) viewAll(rows []T) any {
+	if res.View == nil {
+		return rows
+	}
+	views := make([]any, len(rows))
+	for i := range rows {
+		views[i] = res.View(&rows[i])
+	}
+	return views
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteResourceDocs writes the API reference of the resources as markdown:
+// their endpoints, list parameters, includes and writable fields, all read
+// from the declarations so the reference can't drift from the routes
+func WriteResourceDocs(w io.Writer, resources []Registered) error {
+	var b strings.Builder
+	b.WriteString("# Resources\n\n")
+	b.WriteString("<!-- Generated by `go run . -docs > docs/RESOURCES.md`, do not edit -->\n\n")
+	b.WriteString("Every path is under `/api/v1`. Where several permissions are listed, any one of them allows the request.\n")
+
+	for _, reg := range resources {
+		spec := reg.Spec()
+		sch, err := modelSchema(spec.Model)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&b, "\n## %s\n\n", spec.Name)
+		b.WriteString("| Method | Path | Permission | |\n|---|---|---|---|\n")
+		for _, route := range reg.Routes(nil) {
+			fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", route.Method, route.Path, codes(route.Permissions, " or "), route.Summary)
+		}
+
+		list := spec.List
+		fmt.Fprintf(&b, "\n- Sort: %s (default `%s`)\n", codes(list.Sort, ", "), list.Default)
+		if len(list.Equal) > 0 {
+			fmt.Fprintf(&b, "- Filters: %s\n", codes(list.Equal, ", "))
+		}
+		if len(list.Range) > 0 {
+			ranges := make([]string, len(list.Range))
+			for i, column := range list.Range {
+				ranges[i] = column + "_from`, `" + column + "_to"
+			}
+			fmt.Fprintf(&b, "- Ranges: %s\n", codes(ranges, ", "))
+		}
+		fmt.Fprintf(&b, "- Page size: %d\n", list.PerPage)
+		if len(spec.Includes) > 0 {
+			fmt.Fprintf(&b, "- Includes: %s\n", codes(spec.Includes, ", "))
+		}
+		if spec.Checked {
+			b.WriteString("- Writes are checked against related rows (see [LECTURE_RULES.md](LECTURE_RULES.md))\n")
+		}
+		if spec.Viewed {
+			b.WriteString("- Responses leave out some fields of the model\n")
+		}
+
+		b.WriteString("\n| Field | Type | Rules |\n|---|---|---|\n")
+		for _, field := range inputFields(sch, spec.Input) {
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", jsonName(field), field.FieldType, codes(splitRules(field.Tag.Get("validate")), ", "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// codes formats names as inline code
+func codes(names []string, sep string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return "`" + strings.Join(names, "`"+sep+"`") + "`"
+}
+
+func splitRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
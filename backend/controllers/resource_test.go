@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"tms-server/models"
+)
+
+func TestVerifyInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   any
+		wantErr string
+	}{
+		{"no input", nil, ""},
+		{"user input", models.UserInput{}, ""},
+		{"hidden field", struct{ Password string }{}, "input field Password"},
+		{"unknown field", struct{ Nickname string }{}, "input field Nickname"},
+		{"wrong type", struct{ Email string }{}, "input field Email"},
+		{"not a struct", "Username", "input must be a struct"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Resource[models.User]{
+				Name:  "user",
+				Read:  []string{models.PermUserManage},
+				Write: []string{models.PermUserManage},
+				Input: tt.input,
+			}
+			err := res.Verify()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeInput(t *testing.T) {
+	res := &Resource[models.User]{Name: "user", Input: models.UserInput{}}
+	body := `{"Username": "jane", "Role": "admin", "TOTPEnabled": true, "ExternalID": "https://idp|jane", "Password": "x"}`
+
+	var user models.User
+	if err := res.decode([]byte(body), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "jane" || user.Role != "admin" {
+		t.Errorf("input fields not decoded: %+v", user)
+	}
+	if user.TOTPEnabled || user.ExternalID != nil || user.Password != "" {
+		t.Errorf("fields outside the input were decoded: %+v", user)
+	}
+
+	sch, err := modelSchema(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, field := range inputFields(sch, res.Input) {
+		names = append(names, field.Name)
+	}
+	if got := strings.Join(names, ","); got != "Username,Role,DepartmentID,Email" {
+		t.Errorf("writable fields = %s", got)
+	}
+}
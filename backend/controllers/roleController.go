@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"tms-server/apierror"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type roleInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// GetPermissions lists every permission that can be granted to a role
+func GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := tenantDB(c).Order("name").Find(&permissions).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch permissions")
+		return
+	}
+	c.JSON(http.StatusOK, permissions)
+}
+
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := tenantDB(c).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch roles")
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+func CreateRole(c *gin.Context) {
+	var input roleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Name == "" {
+		apierror.Abort(c, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	permissions, err := findPermissions(tenantDB(c), input.Permissions)
+	if err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var count int64
+	tenantDB(c).Model(&models.Role{}).Where("name = ?", input.Name).Count(&count)
+	if count > 0 {
+		apierror.Abort(c, http.StatusConflict, "a role with this name already exists")
+		return
+	}
+
+	role := models.Role{Name: input.Name, Description: input.Description, Permissions: permissions}
+	if err := tenantDB(c).Create(&role).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to create role")
+		return
+	}
+
+	utils.InvalidatePermissions()
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole replaces a role's description and permissions. Roles are
+// referenced by name from users, so the name can't change.
+func UpdateRole(c *gin.Context) {
+	var input roleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var role models.Role
+	if err := tenantDB(c).First(&role, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Not found")
+		return
+	}
+	if input.Name != "" && input.Name != role.Name {
+		apierror.Abort(c, http.StatusBadRequest, "role names can't be changed")
+		return
+	}
+
+	// Keep at least one way back in: superadmin can always manage roles
+	if role.Name == models.RoleSuperAdmin && !slices.Contains(input.Permissions, models.PermRoleManage) {
+		apierror.Abort(c, http.StatusBadRequest, "the superadmin role must keep "+models.PermRoleManage)
+		return
+	}
+
+	permissions, err := findPermissions(tenantDB(c), input.Permissions)
+	if err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("description", input.Description).Error; err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to update role")
+		return
+	}
+	role.Permissions = permissions
+
+	utils.InvalidatePermissions()
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a custom role that no user has
+func DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := tenantDB(c).First(&role, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Not found")
+		return
+	}
+	if _, builtIn := models.DefaultRolePermissions[role.Name]; builtIn {
+		apierror.Abort(c, http.StatusBadRequest, "built-in roles can't be deleted")
+		return
+	}
+
+	var users int64
+	tenantDB(c).Model(&models.User{}).Where("role = ?", role.Name).Count(&users)
+	if users > 0 {
+		apierror.Abort(c, http.StatusConflict, fmt.Sprintf("role is assigned to %d user(s)", users))
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to delete role")
+		return
+	}
+
+	utils.InvalidatePermissions()
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+func findPermissions(db *gorm.DB, names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+
+	if err := db.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(permissions, func(p models.Permission) bool { return p.Name == name }) {
+			return nil, errors.New("unknown permission: " + name)
+		}
+	}
+	return permissions, nil
+}
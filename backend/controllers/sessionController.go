@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var validSessionStatuses = map[string]bool{
+	models.SessionStatusHeld:      true,
+	models.SessionStatusCancelled: true,
+	"":                            true, // not marked yet
+}
+
+// ownSessions restricts a session query to sessions taught by the faculty:
+// their own lectures unless someone substitutes, plus sessions they substitute
+func ownSessions(query *gorm.DB, facultyID uint) *gorm.DB {
+	return query.
+		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+		Where("(sessions.substitute_faculty_id = ?) OR (sessions.substitute_faculty_id IS NULL AND lectures.faculty_id = ?)",
+			facultyID, facultyID)
+}
+
+// withSessionDetails preloads what a faculty needs to recognise a session
+func withSessionDetails(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("Lecture.Subject").
+		Preload("Lecture.Batch.Course").
+		Preload("Lecture.Room")
+}
+
+// GetMySessions lists the sessions of the logged-in faculty, optionally
+// between 'from' and 'to' (YYYY-MM-DD) and by 'status'
+func GetMySessions(c *gin.Context) {
+	faculty, err := currentFaculty(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusForbidden, "No faculty profile is linked to your account")
+		return
+	}
+
+	query := ownSessions(tenantDB(c).Model(&models.Session{}), faculty.ID)
+
+	if from := c.Query("from"); from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "invalid 'from' date, use YYYY-MM-DD")
+			return
+		}
+		query = query.Where("sessions.date >= ?", date)
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "invalid 'to' date, use YYYY-MM-DD")
+			return
+		}
+		query = query.Where("sessions.date <= ?", date)
+	}
+	if status, ok := c.GetQuery("status"); ok {
+		query = query.Where("COALESCE(sessions.status, '') = ?", status)
+	}
+
+	var sessions []models.Session
+	err = withSessionDetails(query).
+		Order("sessions.date, lectures.start_time").
+		Find(&sessions).Error
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to fetch sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// UpdateSessionStatus marks a session as held or cancelled. Roles with
+// session:mark may mark any session, session:mark_own only the ones they teach.
+func UpdateSessionStatus(c *gin.Context) {
+	var input struct {
+		Status *string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validSessionStatuses[*input.Status] {
+		apierror.Abort(c, http.StatusBadRequest, "status must be 'held', 'cancelled' or empty")
+		return
+	}
+
+	query, err := scopeToDepartment(c, tenantDB(c), &models.Session{})
+	if err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var session models.Session
+	if err := query.First(&session, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if !utils.HasPermission(c.GetUint("tenant_id"), c.GetString("role"), models.PermSessionMark) {
+		faculty, err := currentFaculty(c)
+		if err != nil {
+			apierror.Abort(c, http.StatusForbidden, "No faculty profile is linked to your account")
+			return
+		}
+
+		var owned int64
+		sessionQuery := tenantDB(c).Model(&models.Session{}).Where("sessions.id = ?", session.ID)
+		if err := ownSessions(sessionQuery, faculty.ID).Count(&owned).Error; err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "failed to fetch session")
+			return
+		}
+		if owned == 0 {
+			apierror.Abort(c, http.StatusForbidden, "You can only mark your own sessions")
+			return
+		}
+	}
+
+	if err := ifMatchError(c.GetHeader("If-Match"), &session); err != nil {
+		respondWriteError(c, err)
+		return
+	}
+
+	before := session
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		// Only if nobody marked or changed the session since it was read
+		result := tx.Model(&session).Where("version = ?", session.Version).Update("status", *input.Status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var current models.Session
+			if err := tx.First(&current, session.ID).Error; err != nil {
+				return errNotFound
+			}
+			return staleError(&current)
+		}
+		session.Status = *input.Status
+		session.Version++
+		return recordAudit(c, tx, models.AuditStatus, &session, &before, &session)
+	})
+	if err != nil {
+		respondWriteError(c, err)
+		return
+	}
+
+	setETag(c, &session)
+	c.JSON(http.StatusOK, session)
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"tms-server/config"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenantDB returns the database bound to the request's tenant
+func tenantDB(c *gin.Context) *gorm.DB {
+	return forTenant(c, config.DB)
+}
+
+// forTenant binds db to the request context, whose tenant the tenant scope
+// callbacks add to every statement
+func forTenant(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return db.WithContext(c.Request.Context())
+}
@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var errTOTPNotPending = errors.New("no pending TOTP enrollment, call enroll first")
+
+// requireSecondFactor answers a correct password for a user who needs TOTP
+// with a short-lived MFA token instead of an auth cookie
+func requireSecondFactor(c *gin.Context, user *models.User) {
+	mfaToken, err := utils.GenerateMFAToken(user)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Second factor required",
+		"mfa_required":        true,
+		"enrollment_required": !user.TOTPEnabled,
+		"mfa_token":           mfaToken,
+	})
+}
+
+// LoginTOTP completes a two-step login with a TOTP or recovery code
+func LoginTOTP(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, ok := mfaUser(c, input.MFAToken)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Abort(c, http.StatusBadRequest, "TOTP enrollment required")
+		return
+	}
+
+	ip := c.ClientIP()
+	throttle := utils.Throttle()
+	if wait := throttle.Check(user.TenantID, user.Username, ip); wait > 0 {
+		recordLoginAttempt(c, user.Username, ip, false, "locked")
+		tooManyAttempts(c, wait)
+		return
+	}
+
+	if !verifySecondFactor(tenantDB(c), user, input.Code, input.RecoveryCode) {
+		recordLoginAttempt(c, user.Username, ip, false, "invalid_totp")
+		if wait := throttle.Fail(user.TenantID, user.Username, ip); wait > 0 {
+			tooManyAttempts(c, wait)
+			return
+		}
+		apierror.Abort(c, http.StatusUnauthorized, "Invalid authentication code")
+		return
+	}
+
+	throttle.Succeed(user.TenantID, user.Username)
+	recordLoginAttempt(c, user.Username, ip, true, "totp")
+	issueSession(c, user, nil)
+}
+
+// LoginTOTPEnroll starts enrollment during login for a user whose role
+// requires TOTP but who has not set it up yet
+func LoginTOTPEnroll(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, ok := mfaUser(c, input.MFAToken)
+	if !ok {
+		return
+	}
+	startTOTPEnrollment(c, user)
+}
+
+// LoginTOTPConfirm finishes enrollment during login and issues the auth cookie
+func LoginTOTPConfirm(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, ok := mfaUser(c, input.MFAToken)
+	if !ok {
+		return
+	}
+
+	codes, ok := confirmTOTPEnrollment(c, user, input.Code)
+	if !ok {
+		return
+	}
+
+	recordLoginAttempt(c, user.Username, c.ClientIP(), true, "totp")
+	issueSession(c, user, gin.H{"recovery_codes": codes})
+}
+
+// EnrollTOTP starts TOTP enrollment for the logged-in user
+func EnrollTOTP(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, "User not found")
+		return
+	}
+	startTOTPEnrollment(c, user)
+}
+
+// ConfirmTOTP enables TOTP once the user proves their app produces valid codes
+func ConfirmTOTP(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	codes, ok := confirmTOTPEnrollment(c, user, input.Code)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns off TOTP for the logged-in user unless their role requires it
+func DisableTOTP(c *gin.Context) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Abort(c, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	if utils.TOTPRequired(user.Role) {
+		apierror.Abort(c, http.StatusForbidden, "Two-factor authentication is required for your role")
+		return
+	}
+	if !throttledCode(c, user, func() bool {
+		return verifySecondFactor(tenantDB(c), user, input.Code, input.RecoveryCode)
+	}) {
+		return
+	}
+
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Updates(map[string]any{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error
+	})
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+
+	log.Printf("User '%s' disabled two-factor authentication", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the logged-in user
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		apierror.Abort(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Abort(c, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	if !throttledCode(c, user, func() bool {
+		return verifySecondFactor(tenantDB(c), user, input.Code, "")
+	}) {
+		return
+	}
+
+	var codes []string
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to generate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// mfaUser resolves the user behind an MFA token, responding with 401 if the
+// token is invalid, expired or was issued for another tenant
+func mfaUser(c *gin.Context, mfaToken string) (*models.User, bool) {
+	claims, err := utils.ValidateMFAToken(mfaToken)
+	if err != nil || claims.TenantID != c.GetUint("tenant_id") {
+		apierror.Abort(c, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return nil, false
+	}
+
+	// By ID, which stays the same if the user is renamed meanwhile
+	var user models.User
+	if err := tenantDB(c).Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		apierror.Abort(c, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return nil, false
+	}
+	return &user, true
+}
+
+func startTOTPEnrollment(c *gin.Context, user *models.User) {
+	if user.TOTPEnabled {
+		apierror.Abort(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to generate secret")
+		return
+	}
+	if err := tenantDB(c).Model(user).Update("totp_secret", secret).Error; err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to start enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(secret, user.Username),
+	})
+}
+
+func confirmTOTPEnrollment(c *gin.Context, user *models.User, code string) ([]string, bool) {
+	if user.TOTPEnabled {
+		apierror.Abort(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return nil, false
+	}
+	if user.TOTPSecret == "" {
+		apierror.Abort(c, http.StatusBadRequest, errTOTPNotPending.Error())
+		return nil, false
+	}
+
+	var step int64
+	if !throttledCode(c, user, func() bool {
+		var ok bool
+		step, ok = utils.ValidateTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+		return ok
+	}) {
+		return nil, false
+	}
+
+	var codes []string
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]any{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "failed to enable two-factor authentication")
+		return nil, false
+	}
+
+	log.Printf("User '%s' enabled two-factor authentication", user.Username)
+	return codes, true
+}
+
+// throttledCode runs the check of a code the user entered through the login
+// throttle, keyed by their ID. It answers 429 while they are locked out and
+// 401 for a wrong code, so a session can't be used to guess codes at will.
+func throttledCode(c *gin.Context, user *models.User, valid func() bool) bool {
+	throttle := utils.Throttle()
+	if wait := throttle.CheckCode(user.TenantID, user.ID); wait > 0 {
+		tooManyAttempts(c, wait)
+		return false
+	}
+	if !valid() {
+		if wait := throttle.FailCode(user.TenantID, user.ID); wait > 0 {
+			log.Printf("Second factor locked for user '%s' for %s", user.Username, wait)
+			tooManyAttempts(c, wait)
+			return false
+		}
+		apierror.Abort(c, http.StatusUnauthorized, "Invalid authentication code")
+		return false
+	}
+	throttle.SucceedCode(user.TenantID, user.ID)
+	return true
+}
+
+// verifySecondFactor checks a TOTP code, or else a recovery code, consuming
+// whichever one matched
+func verifySecondFactor(db *gorm.DB, user *models.User, code, recoveryCode string) bool {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+		if !ok {
+			return false
+		}
+		result := db.Model(user).
+			Where("totp_last_step < ?", step).
+			Update("totp_last_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	if recoveryCode != "" {
+		result := db.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashOpaqueToken(recoveryCode)).
+			Update("used_at", time.Now())
+		if result.Error == nil && result.RowsAffected > 0 {
+			log.Printf("User '%s' used a recovery code", user.Username)
+			return true
+		}
+	}
+
+	return false
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, hashes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]models.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
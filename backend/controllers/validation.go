@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"tms-server/apierror"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// validateRow checks the model's validate rules on the given fields, or on
+// every writable field if fields is nil, and answers 422 listing each
+// invalid field
+func validateRow(db *gorm.DB, model any, fields []*schema.Field) error {
+	if fields == nil {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		fields = writableFields(stmt.Schema)
+	}
+
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+
+	if errs := utils.ValidateFields(model, names); len(errs) > 0 {
+		return &apierror.Error{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    apierror.CodeValidation,
+			Message: "Validation failed",
+			Details: gin.H{"fields": errs},
+		}
+	}
+	return nil
+}
+
+// checkRelations runs the resource's Check on a written row, if it has one.
+// Like validation, an update only answers for rules involving the fields it
+// changed.
+func checkRelations[T any](tx *gorm.DB, res *Resource[T], model *T, fields []*schema.Field) error {
+	if res.Check == nil {
+		return nil
+	}
+	violations, err := res.Check(model, tx)
+	if err != nil {
+		return err
+	}
+
+	if fields != nil {
+		violations = slices.DeleteFunc(violations, func(v models.Violation) bool {
+			return !slices.ContainsFunc(fields, func(f *schema.Field) bool {
+				return slices.Contains(v.Fields, f.Name)
+			})
+		})
+	}
+	if len(violations) > 0 {
+		return &apierror.Error{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    apierror.CodeRuleViolation,
+			Message: "Conflicts with related resources",
+			Details: gin.H{"violations": violations},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"tms-server/apierror"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// respondWriteError answers a failed createRow, updateRow or deleteRow
+func respondWriteError(c *gin.Context, err error) {
+	var e *apierror.Error
+	if errors.As(err, &e) && e.Code == apierror.CodeVersionMismatch {
+		setETag(c, e.Details["current"])
+	}
+	apierror.Respond(c, err)
+}
+
+// dbError names the fields of model in a constraint violation writing it
+func dbError(db *gorm.DB, model any, err error) error {
+	stmt := &gorm.Statement{DB: db}
+	if stmt.Parse(model) != nil {
+		return err
+	}
+	if e := apierror.FromDB(err, stmt.Schema); e != nil {
+		return e
+	}
+	return err
+}
+
+var errNoCaller = apierror.New(http.StatusUnauthorized, "User not found")
+var errNotFound = apierror.New(http.StatusNotFound, "Not found")
+
+func departmentError(err error) error {
+	if errors.Is(err, errOutsideDepartment) {
+		return apierror.New(http.StatusForbidden, err.Error())
+	}
+	return err
+}
+
+// createRow inserts a row in the caller's department and audits it
+func createRow[T any](c *gin.Context, tx *gorm.DB, res *Resource[T], model *T) error {
+	// Every row starts at version 1, whatever the body says
+	setModelVersion(model, 0)
+
+	if err := validateRow(tx, model, nil); err != nil {
+		return err
+	}
+	if err := assignCallerDepartment(c, model); err != nil {
+		return errNoCaller
+	}
+	if err := tx.Create(model).Error; err != nil {
+		return dbError(tx, model, err)
+	}
+	if err := checkInDepartment(c, tx, model); err != nil {
+		return departmentError(err)
+	}
+	if err := checkRelations(tx, res, model, nil); err != nil {
+		return err
+	}
+	return recordAudit(c, tx, models.AuditCreate, model, nil, model)
+}
+
+// updateRow applies doc to the row with the given ID, replacing it entirely
+// if replace is set, and audits the change
+func updateRow[T any](c *gin.Context, tx *gorm.DB, res *Resource[T], id any, doc map[string]json.RawMessage, replace bool, ifMatch string) (*T, error) {
+	var model T
+	query, err := scopeToDepartment(c, tx, &model)
+	if err != nil {
+		return nil, errNoCaller
+	}
+	if err := query.First(&model, id).Error; err != nil {
+		return nil, errNotFound
+	}
+	if err := ifMatchError(ifMatch, res.view(&model)); err != nil {
+		return nil, err
+	}
+	version := modelVersion(&model)
+
+	// Snapshot before applying the body, which writes through pointer fields
+	before, err := auditJSON(&model)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := applyFields(tx, &model, res.Input, doc, replace)
+	if err != nil {
+		return nil, apierror.New(http.StatusBadRequest, err.Error())
+	}
+
+	if len(fields) > 0 {
+		// A patch only answers for the fields it changes
+		if err := validateRow(tx, &model, fields); err != nil {
+			return nil, err
+		}
+
+		columns := make([]string, len(fields))
+		for i, field := range fields {
+			columns[i] = field.DBName
+		}
+		// Only if nobody wrote the row since it was read
+		result := tx.Model(&model).Where("version = ?", version).Select(columns).Updates(&model)
+		if result.Error != nil {
+			return nil, dbError(tx, &model, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, currentRowError(c, tx, res, id)
+		}
+		setModelVersion(&model, version+1)
+	}
+	if err := checkInDepartment(c, tx, &model); err != nil {
+		return nil, departmentError(err)
+	}
+	if len(fields) > 0 {
+		if err := checkRelations(tx, res, &model, fields); err != nil {
+			return nil, err
+		}
+	}
+	return &model, recordAudit(c, tx, models.AuditUpdate, &model, before, &model)
+}
+
+// deleteRow soft-deletes the row with the given ID after applying the delete
+// policies of its dependents, and audits it
+func deleteRow[T any](c *gin.Context, tx *gorm.DB, res *Resource[T], id any, ifMatch string) error {
+	var model T
+	query, err := scopeToDepartment(c, tx, &model)
+	if err != nil {
+		return errNoCaller
+	}
+	if err := query.First(&model, id).Error; err != nil {
+		return errNotFound
+	}
+	if err := ifMatchError(ifMatch, res.view(&model)); err != nil {
+		return err
+	}
+
+	resource, ids := resourceType(&model), []uint{modelID(&model)}
+	blocking, err := blockingDependents(tx, resource, ids)
+	if err != nil {
+		return err
+	}
+	if len(blocking) > 0 {
+		return &apierror.Error{
+			Status:  http.StatusConflict,
+			Code:    apierror.CodeHasDependents,
+			Message: "Cannot delete, other resources depend on it",
+			Details: gin.H{"dependents": blocking},
+		}
+	}
+
+	// The row and everything cascaded from it share one deleted_at, which is
+	// how restoring it finds them again
+	deletedAt := tx.NowFunc()
+	tx = tx.Session(&gorm.Session{NowFunc: func() time.Time { return deletedAt }})
+	if err := deleteDependents(tx, resource, ids); err != nil {
+		return err
+	}
+	result := tx.Where("version = ?", modelVersion(&model)).Delete(&model)
+	if result.Error != nil {
+		return dbError(tx, &model, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return currentRowError(c, tx, res, id)
+	}
+	return recordAudit(c, tx, models.AuditDelete, &model, &model, nil)
+}
+
+// currentRowError answers a write that lost a race with the row as it is now
+func currentRowError[T any](c *gin.Context, tx *gorm.DB, res *Resource[T], id any) error {
+	var current T
+	query, err := scopeToDepartment(c, tx, &current)
+	if err != nil {
+		return errNoCaller
+	}
+	if err := query.First(&current, id).Error; err != nil {
+		return errNotFound
+	}
+	return staleError(res.view(&current))
+}
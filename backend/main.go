@@ -2,26 +2,45 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"tms-server/apierror"
 	"tms-server/config"
 	"tms-server/migrations"
 	"tms-server/routes"
+	"tms-server/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func init() {
 	config.LoadEnvVariables()
+	// Gin reads GIN_MODE before .env is loaded
+	gin.SetMode(os.Getenv(gin.EnvGinMode))
 }
 
 func main() {
 	migrate := flag.Bool("migrate", false, "Run database migrations")
+	docs := flag.Bool("docs", false, "Print the API reference of the resources and exit")
 	flag.Parse()
 
+	if *docs {
+		if err := routes.Docs(os.Stdout); err != nil {
+			log.Fatalf("Failed to write docs: %v", err)
+		}
+		return
+	}
+
 	config.ConnectDB()
+	if err := utils.RegisterTenantScope(config.DB); err != nil {
+		log.Fatalf("Failed to register tenant scope: %v", err)
+	}
+	if err := utils.RegisterVersioning(config.DB); err != nil {
+		log.Fatalf("Failed to register versioning: %v", err)
+	}
 
 	// Setup graceful shutdown
 	defer config.ClosePool()
@@ -44,7 +63,15 @@ func main() {
 		return
 	}
 
-	r := gin.Default()
+	utils.StartTrashPurger()
+	utils.StartIdempotencyPurger()
+
+	// Panics are answered like any other server error, without the cause in
+	// release mode
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		apierror.Respond(c, fmt.Errorf("panic: %v", recovered))
+	}))
 	routes.RegisterRoutes(r)
 
 	port := os.Getenv("APP_PORT")
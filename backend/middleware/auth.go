@@ -3,55 +3,88 @@ package middleware
 import (
 	"net/http"
 	"slices"
+	"strings"
+	"tms-server/apierror"
+	"tms-server/models"
 	"tms-server/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	AuthCookie       = "auth_token"
+	AuthMethodCookie = "cookie"
+	AuthMethodBearer = "bearer"
+)
+
+// JWTAuthMiddleware authenticates the request with the auth_token cookie, or
+// with an Authorization: Bearer header when no cookie is sent. A request whose
+// tenant was not named explicitly runs in the token's tenant.
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("auth_token")
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token not found"})
-			c.Abort()
+		tokenString, method := authToken(c)
+		if tokenString == "" {
+			apierror.Abort(c, http.StatusUnauthorized, "Authorization token not found")
 			return
 		}
 
 		claims, err := utils.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, "Invalid or expired token")
 			return
 		}
 
+		// Tokens issued before multi-tenancy belong to the default tenant
+		tenantID := claims.TenantID
+		if tenantID == 0 {
+			tenantID = models.DefaultTenantID
+		}
+		if tenantID != c.GetUint("tenant_id") {
+			if c.GetBool("tenant_explicit") {
+				apierror.Abort(c, http.StatusForbidden, "Token belongs to a different tenant")
+				return
+			}
+			setTenant(c, tenantID)
+		}
+
+		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("auth_method", method)
+		if claims.ImpersonationID != 0 {
+			c.Set("impersonation_id", claims.ImpersonationID)
+			c.Set("impersonator_id", claims.ImpersonatorID)
+			c.Set("impersonator", claims.Impersonator)
+		}
 		c.Next()
 	}
 }
 
-func RoleAuthMiddleware(allowedRoles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
-			c.Abort()
-			return
-		}
+func authToken(c *gin.Context) (string, string) {
+	if token, err := c.Cookie(AuthCookie); err == nil && token != "" {
+		return token, AuthMethodCookie
+	}
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token), AuthMethodBearer
+	}
+	return "", ""
+}
 
-		roleStr, ok := userRole.(string)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid role format"})
-			c.Abort()
+// RequirePermission lets the request through if the user's role grants any
+// of the given permissions
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, tenantID := c.GetString("role"), c.GetUint("tenant_id")
+		if role == "" {
+			apierror.Abort(c, http.StatusUnauthorized, "User role not found")
 			return
 		}
 
-		if slices.Contains(allowedRoles, roleStr) {
+		if slices.ContainsFunc(permissions, func(p string) bool { return utils.HasPermission(tenantID, role, p) }) {
 			c.Next()
 			return
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-		c.Abort()
+		apierror.Abort(c, http.StatusForbidden, "Insufficient permissions")
 	}
 }
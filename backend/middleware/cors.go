@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
+	"tms-server/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -33,9 +36,25 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 
 	return cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		// Tenants with their own frontend list its origins, the rest share the
+		// global list. This runs before the tenant middleware, so that an
+		// unknown tenant's 404 still carries CORS headers the browser can read.
+		AllowOriginWithContextFunc: func(c *gin.Context, origin string) bool {
+			// Preflights never carry X-Tenant, so any tenant's origin may ask
+			if c.Request.Method == http.MethodOptions {
+				return slices.Contains(allowedOrigins, origin) || utils.OriginOfAnyTenant(origin)
+			}
+			tenant, _, err := utils.ResolveTenant(c.GetHeader(TenantHeader), c.Request.Host)
+			if err == nil {
+				if origins := utils.TenantOrigins(tenant.ID); origins != nil {
+					return slices.Contains(origins, origin)
+				}
+			}
+			return slices.Contains(allowedOrigins, origin)
+		},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", CSRFHeader, TenantHeader, IdempotencyHeader, RequestIDHeader, "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"X-Total-Count", "X-Page", "X-Per-Page", "Link", "ETag", "Idempotent-Replayed", RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
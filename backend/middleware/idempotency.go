@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"tms-server/apierror"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotencyMiddleware makes POST requests sent with an Idempotency-Key
+// header safe to retry: the first response is stored and replayed for the
+// same key within IDEMPOTENCY_TTL instead of running the request again.
+// Reusing a key for a different request is rejected. Must run after
+// JWTAuthMiddleware, as keys are per user.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			apierror.Abort(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		ctx, userID := c.Request.Context(), c.GetUint("user_id")
+		claimID, existing, err := utils.ClaimIdempotencyKey(ctx, userID, key, requestHash)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				apierror.Respond(c, &apierror.Error{
+					Status:  http.StatusUnprocessableEntity,
+					Code:    apierror.CodeKeyReused,
+					Message: "Idempotency-Key was already used for a different request",
+				})
+			case existing.Status == 0:
+				apierror.Respond(c, &apierror.Error{
+					Status:  http.StatusConflict,
+					Code:    apierror.CodeKeyInProgress,
+					Message: "A request with this Idempotency-Key is still in progress",
+				})
+			default:
+				if existing.ETag != "" {
+					c.Header("ETag", existing.ETag)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.Status, "application/json; charset=utf-8", existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		// The claim is settled even if the client has gone away, which
+		// cancels the request's context, or the claim would stay in progress
+		settle := context.WithoutCancel(ctx)
+		defer func() {
+			// Recovery runs outside this middleware, so release the key here
+			// for a handler that panicked
+			if r := recover(); r != nil {
+				utils.ReleaseIdempotencyKey(settle, claimID)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors may be transient, so the request can be tried again
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			utils.ReleaseIdempotencyKey(settle, claimID)
+			return
+		}
+		utils.SaveIdempotentResponse(settle, claimID, status, recorder.body.Bytes(), c.Writer.Header().Get("ETag"))
+	}
+}
+
+// responseRecorder keeps a copy of the response body
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
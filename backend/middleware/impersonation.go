@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"tms-server/apierror"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationMiddleware rejects tokens of impersonations that have been
+// stopped and records every request made while impersonating, attributed to
+// the real user. Must run after JWTAuthMiddleware.
+func ImpersonationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		impersonationID := c.GetUint("impersonation_id")
+		if impersonationID == 0 {
+			c.Next()
+			return
+		}
+
+		if !utils.ImpersonationActive(c.Request.Context(), impersonationID) {
+			apierror.Abort(c, http.StatusUnauthorized, "Impersonation has ended")
+			return
+		}
+
+		c.Next()
+
+		utils.RecordImpersonationAction(c.Request.Context(), impersonationID,
+			c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+	}
+}
+
+// ForbidImpersonation blocks routes that only the account owner may use,
+// such as changing the password, while impersonating
+func ForbidImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetUint("impersonation_id") != 0 {
+			apierror.Abort(c, http.StatusForbidden, "Not allowed while impersonating")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"tms-server/apierror"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const TenantHeader = "X-Tenant"
+
+// TenantMiddleware resolves the tenant of the request from the X-Tenant header
+// or the host and binds it to the request context, so every query made with
+// that context only sees the tenant's rows. CORS preflights are left alone, as
+// browsers never send them with the X-Tenant header.
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		tenant, explicit, err := utils.ResolveTenant(c.GetHeader(TenantHeader), c.Request.Host)
+		if err != nil {
+			apierror.Abort(c, http.StatusNotFound, "Unknown tenant")
+			return
+		}
+
+		setTenant(c, tenant.ID)
+		c.Set("tenant_explicit", explicit)
+		c.Next()
+	}
+}
+
+func setTenant(c *gin.Context, tenantID uint) {
+	c.Set("tenant_id", tenantID)
+	c.Request = c.Request.WithContext(utils.WithTenant(c.Request.Context(), tenantID))
+}
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"tms-server/config"
+	"tms-server/models"
+
+	"gorm.io/gorm"
+)
+
+// fixOrphans prepares existing data for the foreign keys: rows pointing at
+// rows that no longer exist get the policy of their foreign key applied, as
+// if the database had enforced it all along. Orphans of restrict keys can't
+// be fixed that way and fail the migration.
+func fixOrphans() error {
+	return fixOrphansIn(config.DB, config.DB.Migrator())
+}
+
+// existingSchema is the part of gorm.Migrator fixOrphansIn needs
+type existingSchema interface {
+	HasTable(model any) bool
+	HasColumn(model any, column string) bool
+}
+
+// fixOrphansIn runs before AutoMigrate, so on a database from an older
+// version some tables and columns don't exist yet. Those hold no references
+// to fix and are skipped.
+func fixOrphansIn(db *gorm.DB, existing existingSchema) error {
+	var problems []string
+	for _, dep := range models.Dependencies {
+		if !existing.HasTable(dep.Model) || !existing.HasTable(dep.Parent) || !existing.HasColumn(dep.Model, dep.Column) {
+			continue
+		}
+		child, parent := tableOf(db, dep.Model), tableOf(db, dep.Parent)
+		orphan := fmt.Sprintf("%s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.id = %s.%s)",
+			dep.Column, parent, child, dep.Column)
+
+		var result *gorm.DB
+		switch dep.OnDelete {
+		case models.OnDeleteSetNull:
+			result = db.Exec(fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s", child, dep.Column, orphan))
+		case models.OnDeleteCascade:
+			result = db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", child, orphan))
+		default:
+			var count int64
+			result = db.Table(child).Where(orphan).Count(&count)
+			if result.Error == nil && count > 0 {
+				problems = append(problems, fmt.Sprintf("%d %s rows reference missing %s via %s", count, child, parent, dep.Column))
+			}
+			if result.Error != nil {
+				return result.Error
+			}
+			continue
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Applied %s to %d %s rows referencing missing %s", dep.OnDelete, result.RowsAffected, child, parent)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("cannot add foreign keys, fix these rows first: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func tableOf(db *gorm.DB, model any) string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		panic(err)
+	}
+	return stmt.Schema.Table
+}
@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// recordingLogger keeps the SQL of every statement
+type recordingLogger struct {
+	logger.Interface
+	statements []string
+}
+
+func (l *recordingLogger) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (l *recordingLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	l.statements = append(l.statements, sql)
+}
+
+// schemaOf is a database's tables and their columns
+type schemaOf struct {
+	db     *gorm.DB
+	tables map[string][]string
+}
+
+func (s schemaOf) HasTable(model any) bool {
+	_, ok := s.tables[tableOf(s.db, model)]
+	return ok
+}
+
+func (s schemaOf) HasColumn(model any, column string) bool {
+	return slices.Contains(s.tables[tableOf(s.db, model)], column)
+}
+
+func TestFixOrphans(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables map[string][]string
+		fixed  []string // child.column checked or fixed
+	}{
+		{
+			name: "baseline schema",
+			tables: map[string][]string{
+				"users":     {"id"},
+				"faculties": {"id", "user_id"},
+				"courses":   {"id"},
+				"batches":   {"id", "course_id"},
+				"subjects":  {"id", "course_id"},
+				"rooms":     {"id"},
+				"lectures":  {"id", "subject_id", "faculty_id", "batch_id", "room_id"},
+				"sessions":  {"id", "lecture_id"},
+			},
+			fixed: []string{
+				"batches.course_id", "subjects.course_id", "lectures.batch_id", "lectures.subject_id",
+				"lectures.faculty_id", "lectures.room_id", "sessions.lecture_id", "faculties.user_id",
+			},
+		},
+		{
+			name: "current schema",
+			tables: map[string][]string{
+				"departments": {"id"},
+				"users":       {"id"},
+				"faculties":   {"id", "user_id"},
+				"courses":     {"id", "department_id"},
+				"batches":     {"id", "course_id"},
+				"subjects":    {"id", "course_id"},
+				"rooms":       {"id"},
+				"lectures":    {"id", "subject_id", "faculty_id", "batch_id", "room_id"},
+				"sessions":    {"id", "lecture_id", "substitute_faculty_id"},
+			},
+			fixed: []string{
+				"courses.department_id", "batches.course_id", "subjects.course_id", "lectures.batch_id",
+				"lectures.subject_id", "lectures.faculty_id", "sessions.substitute_faculty_id",
+				"lectures.room_id", "sessions.lecture_id", "faculties.user_id",
+			},
+		},
+		{name: "empty database"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &recordingLogger{Interface: logger.Discard}
+			db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+				DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: log,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fixOrphansIn(db, schemaOf{db, tt.tables}); err != nil {
+				t.Fatal(err)
+			}
+			if len(log.statements) != len(tt.fixed) {
+				t.Fatalf("ran %d statements, want %d: %q", len(log.statements), len(tt.fixed), log.statements)
+			}
+			for i, statement := range log.statements {
+				if !strings.Contains(statement, tt.fixed[i]) {
+					t.Errorf("statement %d = %q, want one on %s", i, statement, tt.fixed[i])
+				}
+			}
+		})
+	}
+}
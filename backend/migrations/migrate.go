@@ -3,11 +3,44 @@ package migrations
 import (
 	"tms-server/config"
 	"tms-server/models"
+
+	"gorm.io/gorm"
 )
 
+// Unique indexes replaced by per-tenant ones, and then by ones that ignore
+// soft-deleted rows
+var replacedIndexes = []struct {
+	model any
+	name  string
+}{
+	{&models.User{}, "idx_users_username"},
+	{&models.User{}, "idx_users_email"},
+	{&models.User{}, "idx_users_external_id"},
+	{&models.Course{}, "idx_courses_code"},
+	{&models.Subject{}, "idx_subjects_code"},
+	{&models.Room{}, "idx_rooms_name"},
+	{&models.Department{}, "idx_departments_name"},
+	{&models.Department{}, "idx_departments_code"},
+	{&models.Role{}, "idx_roles_name"},
+	{&models.User{}, "idx_users_tenant_username"},
+	{&models.User{}, "idx_users_tenant_email"},
+	{&models.User{}, "idx_users_tenant_external_id"},
+	{&models.Course{}, "idx_courses_tenant_code"},
+	{&models.Subject{}, "idx_subjects_tenant_code"},
+	{&models.Room{}, "idx_rooms_tenant_name"},
+	{&models.Department{}, "idx_departments_tenant_name"},
+	{&models.Department{}, "idx_departments_tenant_code"},
+}
+
 // INFO: for UP and DOWN migration: github.com/golang-migrate/migrate/v4
 func Migrate() error {
+	if err := fixOrphans(); err != nil {
+		return err
+	}
+
 	err := config.DB.AutoMigrate(
+		&models.Tenant{},
+		&models.Department{},
 		&models.User{},
 		&models.Faculty{},
 		&models.Course{},
@@ -16,6 +49,54 @@ func Migrate() error {
 		&models.Room{},
 		&models.Lecture{},
 		&models.Session{},
+		&models.LoginAttempt{},
+		&models.UserToken{},
+		&models.RecoveryCode{},
+		&models.Permission{},
+		&models.Role{},
+		&models.Impersonation{},
+		&models.ImpersonationAction{},
+		&models.AuditLog{},
+		&models.IdempotencyKey{},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	migrator := config.DB.Migrator()
+	for _, idx := range replacedIndexes {
+		if migrator.HasIndex(idx.model, idx.name) {
+			if err := migrator.DropIndex(idx.model, idx.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := hashPlaintextPasswords(); err != nil {
+		return err
+	}
+
+	if err := seedDefaultTenant(); err != nil {
+		return err
+	}
+
+	// One transaction, so a failure in a later tenant doesn't leave the
+	// permissions created and never granted there on the next run
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		byName, added, err := seedPermissions(tx)
+		if err != nil {
+			return err
+		}
+
+		var tenants []models.Tenant
+		if err := tx.Find(&tenants).Error; err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if err := seedRoles(tx, tenant.ID, byName, added); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
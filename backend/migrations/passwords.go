@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"log"
+	"tms-server/config"
+	"tms-server/utils"
+)
+
+// hashPlaintextPasswords hashes passwords stored before hashing was added,
+// or put into the database by hand, as logins only accept bcrypt hashes
+func hashPlaintextPasswords() error {
+	var users []struct {
+		ID       uint
+		Password string
+	}
+	err := config.DB.Table("users").Select("id, password").
+		Where("password <> '' AND password NOT LIKE '$2_$%'").Scan(&users).Error
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		hash, err := utils.HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		if err := config.DB.Exec("UPDATE users SET password = ? WHERE id = ?", hash, user.ID).Error; err != nil {
+			return err
+		}
+	}
+	if len(users) > 0 {
+		log.Printf("Hashed %d plaintext passwords", len(users))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"tms-server/config"
+	"tms-server/models"
+
+	"gorm.io/gorm"
+)
+
+// seedDefaultTenant creates the tenant that rows from before multi-tenancy
+// belong to
+func seedDefaultTenant() error {
+	var count int64
+	if err := config.DB.Model(&models.Tenant{}).Where("id = ?", models.DefaultTenantID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	tenant := models.Tenant{ID: models.DefaultTenantID, Slug: "default", Name: "Default"}
+	if err := config.DB.Create(&tenant).Error; err != nil {
+		return err
+	}
+	// The ID was set explicitly, so move the sequence past it
+	return config.DB.Exec("SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants))").Error
+}
+
+// seedPermissions creates missing permissions, returning all of them by name
+// and which ones it created. Permissions are shared by every tenant, so the
+// added set is only known before the first tenant's roles are seeded.
+func seedPermissions(tx *gorm.DB) (map[string]models.Permission, map[string]bool, error) {
+	byName := make(map[string]models.Permission)
+	added := make(map[string]bool)
+	for _, p := range models.AllPermissions {
+		perm := p
+		result := tx.Where(models.Permission{Name: p.Name}).
+			Attrs(models.Permission{Description: p.Description}).
+			FirstOrCreate(&perm)
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		byName[perm.Name] = perm
+		added[perm.Name] = result.RowsAffected > 0
+	}
+	return byName, added, nil
+}
+
+// seedRoles creates the tenant's built-in roles. Roles that already exist are
+// left alone so edits made by superadmins survive re-running migrations,
+// except that added permissions are granted to the built-in roles that have
+// them by default.
+func seedRoles(tx *gorm.DB, tenantID uint, byName map[string]models.Permission, added map[string]bool) error {
+	for name, permNames := range models.DefaultRolePermissions {
+		var count int64
+		if err := tx.Model(&models.Role{}).Where("tenant_id = ? AND name = ?", tenantID, name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			if err := grantAddedPermissions(tx, tenantID, name, permNames, byName, added); err != nil {
+				return err
+			}
+			continue
+		}
+
+		role := models.Role{TenantID: tenantID, Name: name, Description: "Built-in " + name + " role"}
+		for _, permName := range permNames {
+			role.Permissions = append(role.Permissions, byName[permName])
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func grantAddedPermissions(tx *gorm.DB, tenantID uint, roleName string, permNames []string,
+	byName map[string]models.Permission, added map[string]bool) error {
+	var grant []models.Permission
+	for _, permName := range permNames {
+		if added[permName] {
+			grant = append(grant, byName[permName])
+		}
+	}
+	if len(grant) == 0 {
+		return nil
+	}
+
+	var role models.Role
+	if err := tx.Where("tenant_id = ? AND name = ?", tenantID, roleName).First(&role).Error; err != nil {
+		return err
+	}
+	return tx.Model(&role).Association("Permissions").Append(grant)
+}
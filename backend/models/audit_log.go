@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditStatus   = "status" // session marked held or cancelled
+	AuditRestore  = "restore"
+	AuditSubjects = "subjects" // faculty's assigned subjects replaced
+)
+
+var ErrAuditAppendOnly = errors.New("audit log entries cannot be changed")
+
+// AuditLog is an append-only record of a data change. The actor is always the
+// real user; As is set to the impersonated user when the change was made
+// during an impersonation.
+type AuditLog struct {
+	ID            uint      `gorm:"primaryKey"`
+	TenantID      uint      `gorm:"index;not null;default:1" json:"-"`
+	ActorID       uint      `gorm:"index;not null"`
+	ActorUsername string    `gorm:"index;not null"`
+	AsUsername    string    `json:",omitempty"`
+	Action        string    `gorm:"index;not null"`
+	ResourceType  string    `gorm:"index:idx_audit_logs_resource,priority:1;not null"` // e.g., lecture
+	ResourceID    uint      `gorm:"index:idx_audit_logs_resource,priority:2;not null"`
+	Before        JSON      `gorm:"type:jsonb"`
+	After         JSON      `gorm:"type:jsonb"`
+	CreatedAt     time.Time `gorm:"index"`
+}
+
+func (*AuditLog) BeforeUpdate(*gorm.DB) error { return ErrAuditAppendOnly }
+func (*AuditLog) BeforeDelete(*gorm.DB) error { return ErrAuditAppendOnly }
+
+// JSON is a raw JSON document stored in a jsonb column
+type JSON []byte
+
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j *JSON) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", value)
+	}
+	return nil
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
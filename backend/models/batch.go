@@ -1,10 +1,16 @@
 package models
 
+import "gorm.io/gorm"
+
 type Batch struct {
 	ID       uint   `gorm:"primaryKey"`
-	Year     int    `gorm:"not null"` // e.g., 2023
-	Section  string `gorm:"not null"` // e.g., A, B
-	CourseID uint   `gorm:"not null"`
+	TenantID uint   `gorm:"index;not null;default:1" json:"-"`
+	Year     int    `gorm:"not null" validate:"gte=1950,lte=2100"` // e.g., 2023
+	Section  string `gorm:"not null" validate:"required"`          // e.g., A, B
+	CourseID uint   `gorm:"not null" validate:"required"`
 	Course   Course
-	Lectures []Lecture
+	Lectures []Lecture `gorm:"constraint:OnDelete:CASCADE"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
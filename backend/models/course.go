@@ -1,10 +1,18 @@
 package models
 
+import "gorm.io/gorm"
+
 type Course struct {
 	ID              uint   `gorm:"primaryKey"`
-	Name            string `gorm:"not null"`
-	Code            string `gorm:"uniqueIndex;not null"`
-	Course_Duration int8   `gorm:"not null"`
-	Batches         []Batch
-	Subjects        []Subject
+	TenantID        uint   `gorm:"index;uniqueIndex:idx_courses_tenant_code_active,priority:1;not null;default:1" json:"-"`
+	Name            string `gorm:"not null" validate:"required"`
+	Code            string `gorm:"uniqueIndex:idx_courses_tenant_code_active,priority:2,where:deleted_at IS NULL;not null" validate:"required"`
+	Course_Duration int8   `gorm:"not null" validate:"gt=0"`
+	DepartmentID    *uint  `gorm:"default:null"`
+	Department      *Department
+	Batches         []Batch   `gorm:"constraint:OnDelete:RESTRICT"`
+	Subjects        []Subject `gorm:"constraint:OnDelete:RESTRICT"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,64 @@
+package models
+
+import "gorm.io/gorm"
+
+type Department struct {
+	ID       uint     `gorm:"primaryKey"`
+	TenantID uint     `gorm:"index;uniqueIndex:idx_departments_tenant_name_active,priority:1;uniqueIndex:idx_departments_tenant_code_active,priority:1;not null;default:1" json:"-"`
+	Name     string   `gorm:"uniqueIndex:idx_departments_tenant_name_active,priority:2,where:deleted_at IS NULL;not null" validate:"required"`
+	Code     string   `gorm:"uniqueIndex:idx_departments_tenant_code_active,priority:2,where:deleted_at IS NULL;not null" validate:"required"`
+	Courses  []Course `gorm:"constraint:OnDelete:SET NULL"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// DepartmentScoped is implemented by models that belong to a department,
+// directly or through their course. The scope restricts a query on the
+// model's table to rows of one department.
+type DepartmentScoped interface {
+	ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB
+}
+
+// DepartmentAssignable is implemented by models that carry the department
+// themselves, so it can default to the caller's department on create
+type DepartmentAssignable interface {
+	AssignDepartment(departmentID uint)
+}
+
+// Scopes use subqueries rather than joins so they can be combined with
+// handlers that already join the same tables
+
+func (Department) ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Where("departments.id = ?", departmentID)
+}
+
+func (Course) ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Where("courses.department_id = ?", departmentID)
+}
+
+func (c *Course) AssignDepartment(departmentID uint) {
+	if c.DepartmentID == nil {
+		c.DepartmentID = &departmentID
+	}
+}
+
+func (Batch) ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Where("batches.course_id IN (SELECT id FROM courses WHERE department_id = ?)", departmentID)
+}
+
+func (Subject) ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Where("subjects.course_id IN (SELECT id FROM courses WHERE department_id = ?)", departmentID)
+}
+
+func (Lecture) ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Where(`lectures.batch_id IN (
+		SELECT b.id FROM batches b JOIN courses c ON c.id = b.course_id
+		WHERE c.department_id = ?)`, departmentID)
+}
+
+func (Session) ScopeToDepartment(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Where(`sessions.lecture_id IN (
+		SELECT l.id FROM lectures l JOIN batches b ON b.id = l.batch_id JOIN courses c ON c.id = b.course_id
+		WHERE c.department_id = ?)`, departmentID)
+}
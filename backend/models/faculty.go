@@ -1,9 +1,15 @@
 package models
 
+import "gorm.io/gorm"
+
 type Faculty struct {
 	ID       uint      `gorm:"primaryKey"`
-	Name     string    `gorm:"not null"`
+	TenantID uint      `gorm:"index;not null;default:1" json:"-"`
+	Name     string    `gorm:"not null" validate:"required"`
 	UserID   *uint     `gorm:"default:null"`
-	User     User      `gorm:"foreignKey:UserID"`
-	Subjects []Subject `gorm:"many2many:faculty_subjects;"`
+	User     User      `gorm:"foreignKey:UserID;constraint:OnDelete:SET NULL"`
+	Subjects []Subject `gorm:"many2many:faculty_subjects;constraint:OnDelete:CASCADE"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a POST sent with an
+// Idempotency-Key header, so a retry gets the same response instead of
+// creating the resource a second time. Keys are per user.
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primaryKey"`
+	TenantID    uint   `gorm:"uniqueIndex:idx_idempotency_keys_tenant_user_key,priority:1;not null;default:1" json:"-"`
+	UserID      uint   `gorm:"uniqueIndex:idx_idempotency_keys_tenant_user_key,priority:2;not null"`
+	Key         string `gorm:"uniqueIndex:idx_idempotency_keys_tenant_user_key,priority:3;size:255;not null"`
+	RequestHash string `gorm:"not null"` // SHA-256 of method, path and body
+	Status      int    `gorm:"not null"` // 0 while the first request is running
+	Body        []byte
+	ETag        string    `gorm:"column:etag"`
+	CreatedAt   time.Time `gorm:"index"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Impersonation is a support session in which a user with user:impersonate
+// acts as another user. Everything done during it is attributed to the actor.
+type Impersonation struct {
+	ID             uint      `gorm:"primaryKey"`
+	TenantID       uint      `gorm:"index;not null;default:1" json:"-"`
+	ActorID        uint      `gorm:"index;not null"` // the real user
+	ActorUsername  string    `gorm:"not null"`
+	TargetID       uint      `gorm:"index;not null"` // the impersonated user
+	TargetUsername string    `gorm:"not null"`
+	Reason         string    `gorm:"not null"`
+	ExpiresAt      time.Time `gorm:"not null"`
+	EndedAt        *time.Time
+	CreatedAt      time.Time             `gorm:"index"`
+	Actions        []ImpersonationAction `json:",omitempty"`
+}
+
+func (i *Impersonation) Active() bool {
+	return i.EndedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+// ImpersonationAction is one request made during an impersonation
+type ImpersonationAction struct {
+	ID              uint      `gorm:"primaryKey"`
+	TenantID        uint      `gorm:"index;not null;default:1" json:"-"`
+	ImpersonationID uint      `gorm:"index;not null"`
+	Method          string    `gorm:"not null"`
+	Path            string    `gorm:"not null"`
+	Status          int       `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"index"`
+}
@@ -0,0 +1,31 @@
+package models
+
+const (
+	OnDeleteRestrict = "RESTRICT"
+	OnDeleteCascade  = "CASCADE"
+	OnDeleteSetNull  = "SET NULL"
+)
+
+// Dependency is a foreign key from Model to Parent, with what happens to the
+// dependents when the parent is deleted. The database enforces the same
+// policies through the constraint tags on the models; this list lets soft
+// deletes, which the database doesn't see, follow them too.
+type Dependency struct {
+	Parent   any
+	Model    any
+	Column   string
+	OnDelete string
+}
+
+var Dependencies = []Dependency{
+	{Parent: &Department{}, Model: &Course{}, Column: "department_id", OnDelete: OnDeleteSetNull},
+	{Parent: &Course{}, Model: &Batch{}, Column: "course_id", OnDelete: OnDeleteRestrict},
+	{Parent: &Course{}, Model: &Subject{}, Column: "course_id", OnDelete: OnDeleteRestrict},
+	{Parent: &Batch{}, Model: &Lecture{}, Column: "batch_id", OnDelete: OnDeleteCascade},
+	{Parent: &Subject{}, Model: &Lecture{}, Column: "subject_id", OnDelete: OnDeleteRestrict},
+	{Parent: &Faculty{}, Model: &Lecture{}, Column: "faculty_id", OnDelete: OnDeleteRestrict},
+	{Parent: &Faculty{}, Model: &Session{}, Column: "substitute_faculty_id", OnDelete: OnDeleteSetNull},
+	{Parent: &Room{}, Model: &Lecture{}, Column: "room_id", OnDelete: OnDeleteRestrict},
+	{Parent: &Lecture{}, Model: &Session{}, Column: "lecture_id", OnDelete: OnDeleteCascade},
+	{Parent: &User{}, Model: &Faculty{}, Column: "user_id", OnDelete: OnDeleteSetNull},
+}
@@ -1,19 +1,25 @@
 package models
 
+import "gorm.io/gorm"
+
 type Lecture struct {
 	ID        uint   `gorm:"primaryKey"`
-	DayOfWeek string `gorm:"not null"` // e.g., Monday
-	StartTime string `gorm:"not null"` // Format: "09:00"
-	EndTime   string `gorm:"not null"` // Format: "10:00"
+	TenantID  uint   `gorm:"index;not null;default:1" json:"-"`
+	DayOfWeek string `gorm:"not null" validate:"oneof=Monday Tuesday Wednesday Thursday Friday Saturday Sunday"` // e.g., Monday
+	StartTime string `gorm:"not null" validate:"hhmm"`                                                           // Format: "09:00"
+	EndTime   string `gorm:"not null" validate:"hhmm,later_than=StartTime"`                                      // Format: "10:00"
 
-	SubjectID uint
-	FacultyID uint
-	BatchID   uint
-	Semester  uint
-	RoomID    uint
+	SubjectID uint `validate:"required"`
+	FacultyID uint `validate:"required"`
+	BatchID   uint `validate:"required"`
+	Semester  uint `validate:"gte=1,lte=12"`
+	RoomID    uint `validate:"required"`
 
-	Subject Subject
-	Faculty Faculty
+	Subject Subject `gorm:"constraint:OnDelete:RESTRICT"`
+	Faculty Faculty `gorm:"constraint:OnDelete:RESTRICT"`
 	Batch   Batch
-	Room    Room
+	Room    Room `gorm:"constraint:OnDelete:RESTRICT"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SeverityError   = "error"   // enforced on writes
+	SeverityWarning = "warning" // reported by the lint only
+)
+
+// Violation is a lecture breaking a rule that spans other rows
+type Violation struct {
+	LectureID uint     `json:"lecture_id"`
+	Rule      string   `json:"rule"`
+	Severity  string   `json:"severity"`
+	Fields    []string `json:"fields"` // the lecture fields the rule depends on
+	Message   string   `json:"message"`
+}
+
+// RelationViolations checks a written lecture against the rules enforced on
+// writes, the Check of the lecture resource
+func (l *Lecture) RelationViolations(tx *gorm.DB) ([]Violation, error) {
+	return LintLectures(tx.Model(&Lecture{}).Where("lectures.id = ?", l.ID), SeverityError)
+}
+
+// lectureRule finds lectures breaking a rule. The query selects lecture_id
+// and two values for the message, actual and expected.
+type lectureRule struct {
+	Name     string
+	Severity string
+	Fields   []string
+	Query    func(*gorm.DB) *gorm.DB
+	Message  func(actual, expected string) string
+}
+
+// overlapping joins the other live lectures at an overlapping time on the
+// same day that share column with the lecture
+func overlapping(column string) func(*gorm.DB) *gorm.DB {
+	return func(q *gorm.DB) *gorm.DB {
+		return q.Select("lectures.id AS lecture_id, other.id::text AS actual, '' AS expected").
+			Joins(`JOIN lectures other ON other.` + column + ` = lectures.` + column + `
+				AND other.id <> lectures.id AND other.tenant_id = lectures.tenant_id AND other.deleted_at IS NULL
+				AND other.day_of_week = lectures.day_of_week
+				AND other.start_time < lectures.end_time AND lectures.start_time < other.end_time`)
+	}
+}
+
+var lectureRules = []lectureRule{
+	{
+		Name:     "subject_course",
+		Severity: SeverityError,
+		Fields:   []string{"SubjectID", "BatchID"},
+		Query: func(q *gorm.DB) *gorm.DB {
+			return q.Select("lectures.id AS lecture_id, subjects.course_id::text AS actual, batches.course_id::text AS expected").
+				Joins("JOIN subjects ON subjects.id = lectures.subject_id").
+				Joins("JOIN batches ON batches.id = lectures.batch_id").
+				Where("subjects.course_id <> batches.course_id")
+		},
+		Message: func(actual, expected string) string {
+			return fmt.Sprintf("The subject belongs to course %s but the batch to course %s", actual, expected)
+		},
+	},
+	{
+		Name:     "semester_duration",
+		Severity: SeverityError,
+		Fields:   []string{"Semester", "BatchID"},
+		Query: func(q *gorm.DB) *gorm.DB {
+			// Two semesters a year; courses without a duration can't be checked
+			return q.Select("lectures.id AS lecture_id, lectures.semester::text AS actual, (courses.course_duration * 2)::text AS expected").
+				Joins("JOIN batches ON batches.id = lectures.batch_id").
+				Joins("JOIN courses ON courses.id = batches.course_id").
+				Where("courses.course_duration > 0 AND lectures.semester > courses.course_duration * 2")
+		},
+		Message: func(actual, expected string) string {
+			return fmt.Sprintf("Semester %s is beyond the course's %s semesters", actual, expected)
+		},
+	},
+	{
+		Name:     "faculty_subject",
+		Severity: SeverityError,
+		Fields:   []string{"FacultyID", "SubjectID"},
+		Query: func(q *gorm.DB) *gorm.DB {
+			return q.Select("lectures.id AS lecture_id, lectures.faculty_id::text AS actual, lectures.subject_id::text AS expected").
+				Where("EXISTS (SELECT 1 FROM faculty_subjects fs WHERE fs.faculty_id = lectures.faculty_id)").
+				Where("NOT EXISTS (SELECT 1 FROM faculty_subjects fs WHERE fs.faculty_id = lectures.faculty_id AND fs.subject_id = lectures.subject_id)")
+		},
+		Message: func(actual, expected string) string {
+			return fmt.Sprintf("Faculty %s is not assigned to subject %s", actual, expected)
+		},
+	},
+	{
+		Name:     "faculty_unassigned",
+		Severity: SeverityWarning,
+		Fields:   []string{"FacultyID"},
+		Query: func(q *gorm.DB) *gorm.DB {
+			return q.Select("lectures.id AS lecture_id, lectures.faculty_id::text AS actual, '' AS expected").
+				Where("NOT EXISTS (SELECT 1 FROM faculty_subjects fs WHERE fs.faculty_id = lectures.faculty_id)")
+		},
+		Message: func(actual, _ string) string {
+			return fmt.Sprintf("Faculty %s has no assigned subjects, so the subject can't be checked", actual)
+		},
+	},
+	{
+		Name:     "room_clash",
+		Severity: SeverityWarning,
+		Fields:   []string{"RoomID", "DayOfWeek", "StartTime", "EndTime"},
+		Query:    overlapping("room_id"),
+		Message: func(other, _ string) string {
+			return fmt.Sprintf("The room is also used by lecture %s at an overlapping time", other)
+		},
+	},
+	{
+		Name:     "faculty_clash",
+		Severity: SeverityWarning,
+		Fields:   []string{"FacultyID", "DayOfWeek", "StartTime", "EndTime"},
+		Query:    overlapping("faculty_id"),
+		Message: func(other, _ string) string {
+			return fmt.Sprintf("The faculty also teaches lecture %s at an overlapping time", other)
+		},
+	},
+}
+
+// LintLectures checks the lectures selected by query against every rule of
+// the given severity, or all rules if severity is empty
+func LintLectures(query *gorm.DB, severity string) ([]Violation, error) {
+	var violations []Violation
+	for _, rule := range lectureRules {
+		if severity != "" && rule.Severity != severity {
+			continue
+		}
+
+		var rows []struct {
+			LectureID uint
+			Actual    string
+			Expected  string
+		}
+		if err := rule.Query(query.Session(&gorm.Session{})).Order("lectures.id").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			violations = append(violations, Violation{
+				LectureID: row.LectureID,
+				Rule:      rule.Name,
+				Severity:  rule.Severity,
+				Fields:    slices.Clone(rule.Fields),
+				Message:   rule.Message(row.Actual, row.Expected),
+			})
+		}
+	}
+	return violations, nil
+}
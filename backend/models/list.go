@@ -0,0 +1,13 @@
+package models
+
+// ListSpec whitelists the columns a list endpoint can sort and filter by
+type ListSpec struct {
+	Sort    []string // sort=<column> or sort=-<column>
+	Equal   []string // <column>=v, or <column>=v1,v2 for any of several values
+	Range   []string // <column>_from=v and <column>_to=v, both inclusive
+	Default string   // sort when none is given, defaults to id
+
+	// Page size when per_page isn't given. Tables that grow without bound
+	// use a small one; reference data fits on one page by default.
+	PerPage int
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	TenantID  uint      `gorm:"index;not null;default:1" json:"-"`
+	Username  string    `gorm:"index;not null"`
+	IP        string    `gorm:"index;not null"`
+	Success   bool      `gorm:"not null"`
+	Reason    string    // e.g., invalid_credentials, locked
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode is a one-time fallback for a lost TOTP device.
+// Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID       uint   `gorm:"primaryKey"`
+	TenantID uint   `gorm:"index;not null;default:1" json:"-"`
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"not null"`
+	UsedAt   *time.Time
+}
@@ -0,0 +1,105 @@
+package models
+
+// Role is a named bundle of permissions. User.Role holds the role name.
+// Each tenant has its own roles.
+type Role struct {
+	ID          uint   `gorm:"primaryKey"`
+	TenantID    uint   `gorm:"index;uniqueIndex:idx_roles_tenant_name,priority:1;not null;default:1" json:"-"`
+	Name        string `gorm:"uniqueIndex:idx_roles_tenant_name,priority:2;not null"`
+	Description string
+	Permissions []Permission `gorm:"many2many:role_permissions;"`
+}
+
+type Permission struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;not null"` // e.g., lecture:write
+	Description string
+}
+
+const (
+	RoleFaculty    = "faculty"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "superadmin"
+)
+
+const (
+	PermCourseRead     = "course:read"
+	PermCourseWrite    = "course:write"
+	PermSubjectRead    = "subject:read"
+	PermSubjectWrite   = "subject:write"
+	PermFacultyRead    = "faculty:read"
+	PermFacultyWrite   = "faculty:write"
+	PermRoomRead       = "room:read"
+	PermRoomWrite      = "room:write"
+	PermBatchRead      = "batch:read"
+	PermBatchWrite     = "batch:write"
+	PermLectureRead    = "lecture:read"
+	PermLectureWrite   = "lecture:write"
+	PermSessionRead    = "session:read"
+	PermSessionWrite   = "session:write"
+	PermSessionMark    = "session:mark"
+	PermSessionMarkOwn = "session:mark_own"
+	PermCalendarRead   = "calendar:read"
+	PermDeptRead       = "department:read"
+	PermDeptWrite      = "department:write"
+	PermDeptAll        = "department:all"
+	PermInvitation     = "invitation:create"
+	PermUserManage     = "user:manage"
+	PermAuthManage     = "auth:manage"
+	PermRoleManage     = "role:manage"
+	PermImpersonate    = "user:impersonate"
+	PermAuditRead      = "audit:read"
+)
+
+// AllPermissions lists every permission the routes check, with a description
+var AllPermissions = []Permission{
+	{Name: PermCourseRead, Description: "View courses"},
+	{Name: PermCourseWrite, Description: "Create, edit and delete courses"},
+	{Name: PermSubjectRead, Description: "View subjects"},
+	{Name: PermSubjectWrite, Description: "Create, edit and delete subjects"},
+	{Name: PermFacultyRead, Description: "View faculty"},
+	{Name: PermFacultyWrite, Description: "Create, edit and delete faculty"},
+	{Name: PermRoomRead, Description: "View rooms"},
+	{Name: PermRoomWrite, Description: "Create, edit and delete rooms"},
+	{Name: PermBatchRead, Description: "View batches"},
+	{Name: PermBatchWrite, Description: "Create, edit and delete batches"},
+	{Name: PermLectureRead, Description: "View the timetable"},
+	{Name: PermLectureWrite, Description: "Create, edit and delete lectures"},
+	{Name: PermSessionRead, Description: "View sessions"},
+	{Name: PermSessionWrite, Description: "Create, edit and delete sessions"},
+	{Name: PermSessionMark, Description: "Mark any session held or cancelled"},
+	{Name: PermSessionMarkOwn, Description: "Mark own sessions held or cancelled"},
+	{Name: PermCalendarRead, Description: "View the calendar"},
+	{Name: PermDeptRead, Description: "View departments"},
+	{Name: PermDeptWrite, Description: "Create, edit and delete departments"},
+	{Name: PermDeptAll, Description: "See and edit every department regardless of own department"},
+	{Name: PermInvitation, Description: "Invite faculty to create an account"},
+	{Name: PermUserManage, Description: "Manage user accounts"},
+	{Name: PermAuthManage, Description: "View login attempts and clear lockouts"},
+	{Name: PermRoleManage, Description: "Edit roles and their permissions"},
+	{Name: PermImpersonate, Description: "Act as another user for support, audit-logged"},
+	{Name: PermAuditRead, Description: "View the audit log of data changes"},
+}
+
+var facultyPermissions = []string{
+	PermCourseRead, PermSubjectRead, PermFacultyRead, PermRoomRead, PermBatchRead,
+	PermLectureRead, PermSessionRead, PermCalendarRead, PermSessionMarkOwn, PermDeptRead,
+}
+
+var adminPermissions = append(append([]string{}, facultyPermissions...),
+	PermCourseWrite, PermSubjectWrite, PermFacultyWrite, PermRoomWrite, PermBatchWrite,
+	PermLectureWrite, PermSessionWrite, PermSessionMark, PermInvitation,
+)
+
+var superAdminPermissions = append(append([]string{}, adminPermissions...),
+	PermUserManage, PermAuthManage, PermRoleManage, PermDeptWrite, PermDeptAll, PermImpersonate,
+	PermAuditRead,
+)
+
+// DefaultRolePermissions are the built-in roles, matching what each role
+// could do before permissions were configurable
+var DefaultRolePermissions = map[string][]string{
+	RoleFaculty:    facultyPermissions,
+	RoleAdmin:      adminPermissions,
+	RoleSuperAdmin: superAdminPermissions,
+}
@@ -1,7 +1,13 @@
 package models
 
+import "gorm.io/gorm"
+
 type Room struct {
 	ID       uint   `gorm:"primaryKey"`
-	Name     string `gorm:"uniqueIndex;not null"`
-	Capacity int
+	TenantID uint   `gorm:"index;uniqueIndex:idx_rooms_tenant_name_active,priority:1;not null;default:1" json:"-"`
+	Name     string `gorm:"uniqueIndex:idx_rooms_tenant_name_active,priority:2,where:deleted_at IS NULL;not null" validate:"required"`
+	Capacity int    `validate:"gte=0"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
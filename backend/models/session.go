@@ -1,12 +1,29 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Session struct {
 	ID        uint      `gorm:"primaryKey"`
-	LectureID uint      `gorm:"not null"`
-	Date      time.Time `gorm:"type:date;not null"` // Stores only date (YYYY-MM-DD)
-	Status    string
+	TenantID  uint      `gorm:"index;not null;default:1" json:"-"`
+	LectureID uint      `gorm:"not null" validate:"required"`
+	Date      time.Time `gorm:"type:date;not null" validate:"required"` // Stores only date (YYYY-MM-DD)
+	Status    string    `validate:"omitempty,oneof=held cancelled"`
+
+	// Set when another faculty takes this session instead of the lecture's own
+	SubstituteFacultyID *uint `gorm:"default:null"`
 
-	Lecture Lecture `gorm:"foreignKey:LectureID"`
+	Lecture           Lecture  `gorm:"foreignKey:LectureID;constraint:OnDelete:CASCADE"`
+	SubstituteFaculty *Faculty `gorm:"foreignKey:SubstituteFacultyID;constraint:OnDelete:SET NULL"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
+
+const (
+	SessionStatusHeld      = "held"
+	SessionStatusCancelled = "cancelled"
+)
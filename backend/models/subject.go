@@ -1,10 +1,16 @@
 package models
 
+import "gorm.io/gorm"
+
 type Subject struct {
 	ID        uint   `gorm:"primaryKey"`
-	Name      string `gorm:"not null"`
-	Code      string `gorm:"uniqueIndex;not null"`
-	CourseID  uint   `gorm:"not null"`
+	TenantID  uint   `gorm:"index;uniqueIndex:idx_subjects_tenant_code_active,priority:1;not null;default:1" json:"-"`
+	Name      string `gorm:"not null" validate:"required"`
+	Code      string `gorm:"uniqueIndex:idx_subjects_tenant_code_active,priority:2,where:deleted_at IS NULL;not null" validate:"required"`
+	CourseID  uint   `gorm:"not null" validate:"required"`
 	Course    Course
-	Faculties []Faculty `gorm:"many2many:faculty_subjects;"`
+	Faculties []Faculty `gorm:"many2many:faculty_subjects;constraint:OnDelete:CASCADE"`
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
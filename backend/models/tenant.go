@@ -0,0 +1,17 @@
+package models
+
+// Tenant is an institute hosted on this server. Every model except the
+// Permission catalogue carries a TenantID and is only visible within its tenant.
+type Tenant struct {
+	ID     uint   `gorm:"primaryKey"`
+	Slug   string `gorm:"uniqueIndex;not null"` // subdomain and X-Tenant header value
+	Name   string `gorm:"not null"`
+	Domain string `gorm:"index"` // optional custom host, e.g. timetable.college.edu
+
+	// Comma-separated CORS origins for this tenant's frontend. Empty falls
+	// back to CORS_ALLOWED_ORIGINS.
+	AllowedOrigins string
+}
+
+// DefaultTenantID is the tenant rows created before multi-tenancy belong to
+const DefaultTenantID = 1
@@ -0,0 +1,16 @@
+package models
+
+// Trashable lists the models that are soft-deleted, so they can be listed in
+// the trash, restored, and purged after the retention period. Dependents come
+// before what they depend on, the order purges run in.
+var Trashable = []any{
+	&Session{},
+	&Lecture{},
+	&Batch{},
+	&Subject{},
+	&Faculty{},
+	&Room{},
+	&Course{},
+	&Department{},
+	&User{},
+}
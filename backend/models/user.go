@@ -1,8 +1,64 @@
 package models
 
+import "gorm.io/gorm"
+
 type User struct {
 	ID       uint   `gorm:"primaryKey"`
-	Username string `gorm:"uniqueIndex;not null"`
-	Password string `gorm:"not null"` // hashed password
+	TenantID uint   `gorm:"index;uniqueIndex:idx_users_tenant_username_active,priority:1;uniqueIndex:idx_users_tenant_email_active,priority:1;uniqueIndex:idx_users_tenant_external_id_active,priority:1;not null;default:1" json:"-"`
+	Username string `gorm:"uniqueIndex:idx_users_tenant_username_active,priority:2,where:deleted_at IS NULL;not null" validate:"required"`
+	// bcrypt hash, set through password reset, invitations or SSO rather than
+	// the user endpoints, so it is neither written nor answered as JSON
+	Password string `gorm:"not null" json:"-"`
 	Role     string `gorm:"default:'faculty';not null"`
+
+	// Admins with a department only see and edit that department's data
+	DepartmentID *uint `gorm:"default:null"`
+
+	Email      *string `gorm:"uniqueIndex:idx_users_tenant_email_active,priority:2,where:deleted_at IS NULL;default:null" validate:"omitempty,email"`
+	ExternalID *string `gorm:"uniqueIndex:idx_users_tenant_external_id_active,priority:2,where:deleted_at IS NULL;default:null"` // OIDC issuer + subject
+
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `gorm:"default:false;not null"`
+	TOTPLastStep int64  `json:"-"` // last accepted time step, to reject replayed codes
+
+	Version   uint           `gorm:"not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// UserInput is what the user endpoints may write. The password, second
+// factor and SSO link each have their own flows, so a user can't be created
+// with or given them here.
+type UserInput struct {
+	Username     string
+	Role         string
+	DepartmentID *uint
+	Email        *string
+}
+
+// UserView is a user as the API answers it, leaving the password hash on
+// the server
+type UserView struct {
+	ID           uint
+	Username     string
+	Role         string
+	DepartmentID *uint
+	Email        *string
+	ExternalID   *string
+	TOTPEnabled  bool
+	Version      uint
+	DeletedAt    gorm.DeletedAt
+}
+
+func (u *User) View() any {
+	return UserView{
+		ID:           u.ID,
+		Username:     u.Username,
+		Role:         u.Role,
+		DepartmentID: u.DepartmentID,
+		Email:        u.Email,
+		ExternalID:   u.ExternalID,
+		TOTPEnabled:  u.TOTPEnabled,
+		Version:      u.Version,
+		DeletedAt:    u.DeletedAt,
+	}
 }
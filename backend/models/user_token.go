@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposeReset  = "reset"
+	TokenPurposeInvite = "invite"
+)
+
+// UserToken is a one-time token for password resets and faculty invitations.
+// Only the SHA-256 hash of the token is stored.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	TenantID  uint      `gorm:"index;not null;default:1" json:"-"`
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"`
+	Purpose   string    `gorm:"not null"` // reset or invite
+	UserID    *uint     `gorm:"default:null"`
+	FacultyID *uint     `gorm:"default:null"`
+	CreatedBy string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (t *UserToken) Usable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
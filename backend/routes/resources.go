@@ -0,0 +1,170 @@
+package routes
+
+import (
+	"net/http"
+	"tms-server/controllers"
+	"tms-server/models"
+
+	"gorm.io/gorm"
+)
+
+// resources declares every model served by the generic handlers. Adding an
+// entity is one entry here; its routes, API reference section and startup
+// checks follow from it.
+func resources(db *gorm.DB) []controllers.Registered {
+	return []controllers.Registered{
+		&controllers.Resource[models.Department]{
+			Name:  "department",
+			Read:  []string{models.PermDeptRead},
+			Write: []string{models.PermDeptWrite},
+			List: models.ListSpec{
+				Sort:  []string{"id", "name", "code"},
+				Equal: []string{"name", "code"},
+			},
+			Includes: []string{"Courses"},
+		},
+		&controllers.Resource[models.Course]{
+			Name:  "course",
+			Read:  []string{models.PermCourseRead},
+			Write: []string{models.PermCourseWrite},
+			List: models.ListSpec{
+				Sort:  []string{"id", "name", "code", "course_duration"},
+				Equal: []string{"code", "department_id", "course_duration"},
+			},
+			Includes: []string{"Department", "Batches", "Subjects"},
+		},
+		&controllers.Resource[models.Subject]{
+			Name:  "subject",
+			Read:  []string{models.PermSubjectRead},
+			Write: []string{models.PermSubjectWrite},
+			List: models.ListSpec{
+				Sort:  []string{"id", "name", "code", "course_id"},
+				Equal: []string{"course_id", "code"},
+			},
+			Includes: []string{"Course", "Course.Department", "Faculties"},
+		},
+		&controllers.Resource[models.Faculty]{
+			Name:  "faculty",
+			Read:  []string{models.PermFacultyRead},
+			Write: []string{models.PermFacultyWrite},
+			List: models.ListSpec{
+				Sort:  []string{"id", "name"},
+				Equal: []string{"user_id"},
+			},
+			// User is left out so reading faculty doesn't expose accounts
+			Includes: []string{"Subjects", "Subjects.Course"},
+			Extra: []controllers.Route{
+				{
+					Method:      http.MethodPut,
+					Path:        "/faculty/:id/subjects",
+					Permissions: []string{models.PermFacultyWrite},
+					Summary:     `Replace the subjects a faculty teaches ({"subject_ids": [...]})`,
+					Handler:     controllers.SetFacultySubjects,
+				},
+			},
+		},
+		&controllers.Resource[models.Room]{
+			Name:  "room",
+			Read:  []string{models.PermRoomRead},
+			Write: []string{models.PermRoomWrite},
+			List: models.ListSpec{
+				Sort:  []string{"id", "name", "capacity"},
+				Equal: []string{"name"},
+				Range: []string{"capacity"},
+			},
+		},
+		&controllers.Resource[models.Batch]{
+			Name:  "batch",
+			Read:  []string{models.PermBatchRead},
+			Write: []string{models.PermBatchWrite},
+			List: models.ListSpec{
+				Sort:  []string{"id", "year", "section", "course_id"},
+				Equal: []string{"course_id", "year", "section"},
+				Range: []string{"year"},
+			},
+			Includes: []string{"Course", "Course.Department", "Lectures"},
+		},
+		&controllers.Resource[models.Lecture]{
+			Name:     "lecture",
+			Read:     []string{models.PermLectureRead},
+			Write:    []string{models.PermLectureWrite},
+			Includes: []string{"Subject", "Subject.Course", "Faculty", "Batch", "Batch.Course", "Room"},
+			Check:    (*models.Lecture).RelationViolations,
+			Extra: []controllers.Route{
+				{
+					Method:      http.MethodGet,
+					Path:        "/lecture",
+					Permissions: []string{models.PermLectureRead},
+					Summary:     "Same as /lecture/query, kept for backwards compatibility",
+					Handler:     controllers.QueryLectures(db),
+				},
+				{
+					Method:      http.MethodGet,
+					Path:        "/lecture/query",
+					Permissions: []string{models.PermLectureRead},
+					Summary:     "Timetable entries with batch, subject, faculty and room (filters: batch_id, semester, faculty_id, room_id, year, course_id, section)",
+					Handler:     controllers.QueryLectures(db),
+				},
+				{
+					Method:      http.MethodGet,
+					Path:        "/lecture/lint",
+					Permissions: []string{models.PermLectureRead},
+					Summary:     "Check lectures against the lecture rules (filters: batch_id, semester, severity)",
+					Handler:     controllers.LintLectures,
+				},
+			},
+		},
+		&controllers.Resource[models.Session]{
+			Name:  "session",
+			Read:  []string{models.PermSessionRead},
+			Write: []string{models.PermSessionWrite},
+			List: models.ListSpec{
+				Sort:    []string{"id", "date", "lecture_id", "status"},
+				Equal:   []string{"lecture_id", "status", "substitute_faculty_id"},
+				Range:   []string{"date"},
+				Default: "-date",
+				PerPage: 100,
+			},
+			Includes: []string{
+				"Lecture", "Lecture.Subject", "Lecture.Subject.Course", "Lecture.Faculty",
+				"Lecture.Batch", "Lecture.Batch.Course", "Lecture.Room", "SubstituteFaculty",
+			},
+			Extra: []controllers.Route{
+				{
+					Method:      http.MethodGet,
+					Path:        "/session/mine",
+					Permissions: []string{models.PermSessionMarkOwn},
+					Summary:     "Sessions taught by the logged-in faculty, including substitutions (filters: from, to, status)",
+					Handler:     controllers.GetMySessions,
+				},
+				{
+					Method:      http.MethodPut,
+					Path:        "/session/:id/status",
+					Permissions: []string{models.PermSessionMark, models.PermSessionMarkOwn},
+					Summary:     `Mark a session held, cancelled or unmarked ({"status": "held"}); faculty only their own`,
+					Handler:     controllers.UpdateSessionStatus,
+				},
+			},
+		},
+		&controllers.Resource[models.User]{
+			Name:  "user",
+			Read:  []string{models.PermUserManage},
+			Write: []string{models.PermUserManage},
+			List: models.ListSpec{
+				Sort:  []string{"id", "username", "role"},
+				Equal: []string{"role", "department_id"},
+			},
+			Input: models.UserInput{},
+			View:  (*models.User).View,
+			Extra: []controllers.Route{
+				{
+					Method:      http.MethodPost,
+					Path:        "/user/:id/reset-token",
+					Permissions: []string{models.PermUserManage},
+					Summary:     "Issue a one-time password reset token",
+					Handler:     controllers.CreateResetToken,
+				},
+			},
+		},
+	}
+}
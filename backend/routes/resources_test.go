@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestResources(t *testing.T) {
+	for _, res := range resources(nil) {
+		t.Run(res.Spec().Name, func(t *testing.T) {
+			if err := res.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestResourceDocs fails when docs/RESOURCES.md no longer matches the
+// declarations; regenerate it with go run . -docs > docs/RESOURCES.md
+func TestResourceDocs(t *testing.T) {
+	var generated bytes.Buffer
+	if err := Docs(&generated); err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../docs/RESOURCES.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated.Bytes(), committed) {
+		t.Error("docs/RESOURCES.md is out of date, regenerate it with go run . -docs > docs/RESOURCES.md")
+	}
+}
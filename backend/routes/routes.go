@@ -1,6 +1,9 @@
 package routes
 
 import (
+	"io"
+	"net/http"
+	"tms-server/apierror"
 	"tms-server/config"
 	"tms-server/controllers"
 	"tms-server/middleware"
@@ -11,7 +14,15 @@ import (
 )
 
 func RegisterRoutes(r *gin.Engine) {
+	// The request ID comes first so every error response can carry it, and
+	// CORS before the tenant so its errors can be read by the browser too
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.TenantMiddleware())
+
+	r.NoRoute(func(c *gin.Context) {
+		apierror.Abort(c, http.StatusNotFound, "No such endpoint")
+	})
 
 	db := config.DB
 	api := r.Group("/api/v1")
@@ -23,91 +34,81 @@ func RegisterRoutes(r *gin.Engine) {
 	// Public routes
 	api.GET("/ping", controllers.Ping)
 	api.POST("/login", controllers.Login)
-
-	// Protected routes (faculty+)
+	api.GET("/login/oidc", controllers.OIDCLogin)
+	api.GET("/login/oidc/callback", controllers.OIDCCallback)
+	api.POST("/login/totp", controllers.LoginTOTP)
+	api.POST("/login/totp/enroll", controllers.LoginTOTPEnroll)
+	api.POST("/login/totp/confirm", controllers.LoginTOTPConfirm)
+	api.POST("/password/reset", controllers.ResetPassword)
+	api.POST("/invitation/accept", controllers.AcceptInvitation)
+
+	// Protected routes (any logged-in user)
 	api.Use(middleware.JWTAuthMiddleware())
+	api.Use(middleware.ImpersonationMiddleware())
+	api.Use(middleware.CSRFMiddleware())
+	api.Use(middleware.IdempotencyMiddleware())
+	api.GET("/csrf", controllers.GetCSRFToken)
 	api.POST("/logout", controllers.Logout)
-	registerFacultyRoutes(api, db)
-
-	// Admin-only routes
-	admin := api.Group("/")
-	admin.Use(middleware.RoleAuthMiddleware("admin", "superadmin"))
-	registerAdminRoutes(admin, db)
-
-	// Superadmin-only routes
-	super := api.Group("/")
-	super.Use(middleware.RoleAuthMiddleware("superadmin"))
-	registerSuperAdminRoutes(super, db)
+	api.GET("/me", controllers.GetMe)
+	api.GET("/impersonation/current", controllers.GetCurrentImpersonation)
+	api.POST("/impersonation/stop", controllers.StopImpersonation)
+
+	// Account security is left to the account owner, never an impersonator
+	own := api.Group("/", middleware.ForbidImpersonation())
+	own.POST("/password/change", controllers.ChangePassword)
+	own.POST("/totp/enroll", controllers.EnrollTOTP)
+	own.POST("/totp/confirm", controllers.ConfirmTOTP)
+	own.POST("/totp/disable", controllers.DisableTOTP)
+	own.POST("/totp/recovery-codes", controllers.RegenerateRecoveryCodes)
+	own.GET("/login/oidc/link", controllers.OIDCLink)
+
+	// Everything else is gated by permissions granted to the user's role
+	registerResourceRoutes(api, db)
+	registerOtherRoutes(api)
+	registerManagementRoutes(api)
 }
 
-func registerFacultyRoutes(r *gin.RouterGroup, db *gorm.DB) {
-	r.GET("/course", controllers.All[models.Course](db))
-	r.GET("/course/:id", controllers.Get[models.Course](db))
-
-	r.GET("/subject", controllers.All[models.Subject](db))
-	r.GET("/subject/:id", controllers.Get[models.Subject](db))
-
-	r.GET("/faculty", controllers.All[models.Faculty](db))
-	r.GET("/faculty/:id", controllers.Get[models.Faculty](db))
+var can = middleware.RequirePermission
 
-	r.GET("/room", controllers.All[models.Room](db))
-	r.GET("/room/:id", controllers.Get[models.Room](db))
-
-	r.GET("/batch", controllers.All[models.Batch](db))
-	r.GET("/batch/:id", controllers.Get[models.Batch](db))
-
-	r.GET("/lecture", controllers.QueryLectures(db)) // for backwards compatibility, use /query
-	r.GET("/lecture/query", controllers.QueryLectures(db))
-	r.GET("/lecture/:id", controllers.Get[models.Lecture](db))
-
-	r.GET("/session", controllers.All[models.Session](db))
-	r.GET("/session/:id", controllers.Get[models.Session](db))
+// registerResourceRoutes serves every declared resource. The declarations
+// are checked against their models by the tests of this package.
+func registerResourceRoutes(r *gin.RouterGroup, db *gorm.DB) {
+	for _, res := range resources(db) {
+		for _, route := range res.Routes(db) {
+			r.Handle(route.Method, route.Path, can(route.Permissions...), route.Handler)
+		}
+	}
+}
 
-	r.GET("/calendar", controllers.GetCalendarSummaryByMonth)
-	r.GET("/calendar/day", controllers.GetLectureDetailsByDate)
+// Docs writes the API reference of the declared resources
+func Docs(w io.Writer) error {
+	return controllers.WriteResourceDocs(w, resources(nil))
 }
 
-func registerAdminRoutes(r *gin.RouterGroup, db *gorm.DB) {
-	// Course
-	r.POST("/course", controllers.Create[models.Course](db))
-	r.PUT("/course/:id", controllers.Update[models.Course](db))
-	r.DELETE("/course/:id", controllers.Delete[models.Course](db))
-
-	// Subject
-	r.POST("/subject", controllers.Create[models.Subject](db))
-	r.PUT("/subject/:id", controllers.Update[models.Subject](db))
-	r.DELETE("/subject/:id", controllers.Delete[models.Subject](db))
-
-	// Faculty
-	r.POST("/faculty", controllers.Create[models.Faculty](db))
-	r.PUT("/faculty/:id", controllers.Update[models.Faculty](db))
-	r.DELETE("/faculty/:id", controllers.Delete[models.Faculty](db))
-
-	// Room
-	r.POST("/room", controllers.Create[models.Room](db))
-	r.PUT("/room/:id", controllers.Update[models.Room](db))
-	r.DELETE("/room/:id", controllers.Delete[models.Room](db))
-
-	// Batch
-	r.POST("/batch", controllers.Create[models.Batch](db))
-	r.PUT("/batch/:id", controllers.Update[models.Batch](db))
-	r.DELETE("/batch/:id", controllers.Delete[models.Batch](db))
-
-	// Lecture
-	r.POST("/lecture", controllers.Create[models.Lecture](db))
-	r.PUT("/lecture/:id", controllers.Update[models.Lecture](db))
-	r.DELETE("/lecture/:id", controllers.Delete[models.Lecture](db))
-
-	// Session
-	r.POST("/session", controllers.Create[models.Session](db))
-	r.PUT("/session/:id", controllers.Update[models.Session](db))
-	r.DELETE("/session/:id", controllers.Delete[models.Session](db))
+func registerOtherRoutes(r *gin.RouterGroup) {
+	r.GET("/calendar", can(models.PermCalendarRead), controllers.GetCalendarSummaryByMonth)
+	r.GET("/calendar/day", can(models.PermCalendarRead), controllers.GetLectureDetailsByDate)
+
+	r.POST("/invitation", can(models.PermInvitation), controllers.CreateInvitation)
 }
 
-func registerSuperAdminRoutes(r *gin.RouterGroup, db *gorm.DB) {
-	r.GET("/user", controllers.All[models.User](db))
-	r.POST("/user", controllers.Create[models.User](db))
-	r.GET("/user/:id", controllers.Get[models.User](db))
-	r.PUT("/user/:id", controllers.Update[models.User](db))
-	r.DELETE("/user/:id", controllers.Delete[models.User](db))
+func registerManagementRoutes(r *gin.RouterGroup) {
+	auth := r.Group("/", can(models.PermAuthManage))
+	auth.GET("/auth/attempts", controllers.GetLoginAttempts)
+	auth.GET("/auth/lockouts", controllers.GetLoginLockouts)
+	auth.POST("/auth/unlock", controllers.UnlockLogin)
+
+	roles := r.Group("/", can(models.PermRoleManage))
+	roles.GET("/permission", controllers.GetPermissions)
+	roles.GET("/role", controllers.GetRoles)
+	roles.POST("/role", controllers.CreateRole)
+	roles.PUT("/role/:id", controllers.UpdateRole)
+	roles.DELETE("/role/:id", controllers.DeleteRole)
+
+	r.GET("/audit", can(models.PermAuditRead), controllers.GetAuditLogs)
+
+	impersonation := r.Group("/", can(models.PermImpersonate))
+	impersonation.POST("/impersonation", middleware.ForbidImpersonation(), controllers.StartImpersonation)
+	impersonation.GET("/impersonation", controllers.GetImpersonations)
+	impersonation.GET("/impersonation/:id", controllers.GetImpersonation)
 }
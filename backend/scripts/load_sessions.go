@@ -73,6 +73,7 @@ func loadSessionsForLastDays(days int) error {
 
 				// Create new session with null status
 				session := models.Session{
+					TenantID:  lecture.TenantID,
 					LectureID: lecture.ID,
 					Date:      date,
 					// Status is left as zero value (empty string) which will be treated as NULL
@@ -4,36 +4,124 @@ import (
 	"context"
 	"errors"
 	"log"
+	"os"
+	"strings"
+	"sync"
 	"time"
 	"tms-server/config"
 	"tms-server/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUnknownUser tells the chain to ask the next authenticator
+	ErrUnknownUser = errors.New("unknown user")
+)
+
+// Authenticator verifies a username and password against one account source
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// Authenticators is the chain AuthenticateUser walks, in order. It is built
+// from AUTH_BACKENDS (comma-separated, default "local") on first use.
+var (
+	Authenticators     []Authenticator
+	authenticatorsOnce sync.Once
 )
 
-// TODO: hash password with bcrypt
-func AuthenticateUser(username, password string) (*models.User, error) {
+// AuthenticateUser checks the credentials within the tenant carried by ctx
+func AuthenticateUser(ctx context.Context, username, password string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	authenticatorsOnce.Do(func() {
+		if Authenticators == nil {
+			Authenticators = authenticatorsFromEnv()
+		}
+	})
+
+	for _, a := range Authenticators {
+		user, err := a.Authenticate(ctx, username, password)
+		if err == nil {
+			log.Printf("User '%s' authenticated successfully via %s", username, a.Name())
+			return user, nil
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		if !errors.Is(err, ErrUnknownUser) {
+			// A backend being down must not lock out accounts the next one knows
+			log.Printf("Authenticator %s failed for user '%s': %v", a.Name(), username, err)
+		}
+	}
+
+	return nil, ErrInvalidCredentials
+}
+
+func authenticatorsFromEnv() []Authenticator {
+	names := os.Getenv("AUTH_BACKENDS")
+	if names == "" {
+		names = "local"
+	}
+
+	var chain []Authenticator
+	for _, name := range splitList(names) {
+		switch name {
+		case "local":
+			chain = append(chain, LocalAuthenticator{})
+		case "ldap":
+			chain = append(chain, NewLDAPAuthenticatorFromEnv())
+		default:
+			log.Printf("Ignoring unknown authenticator '%s' in AUTH_BACKENDS", name)
+		}
+	}
+	return chain
+}
+
+// LocalAuthenticator checks the password stored in the users table
+type LocalAuthenticator struct{}
+
+func (LocalAuthenticator) Name() string { return "local" }
+
+func (LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
 	var user models.User
+	var externalID *string
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		tenantID = models.DefaultTenantID
+	}
 
 	// Use pgx pool directly to avoid GORM's prepared statement caching issues
 	// This bypasses the prepared statement problem with Supabase transaction pooling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	query := `SELECT id, tenant_id, username, password, role, totp_enabled, external_id
+		FROM users WHERE username = $1 AND tenant_id = $2 AND deleted_at IS NULL LIMIT 1`
+
+	row := config.Pool.QueryRow(ctx, query, username, tenantID)
 
-	query := `SELECT id, username, password, role FROM users WHERE username = $1 LIMIT 1`
-	
-	row := config.Pool.QueryRow(ctx, query, username)
-	
-	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role)
+	err := row.Scan(&user.ID, &user.TenantID, &user.Username, &user.Password, &user.Role, &user.TOTPEnabled, &externalID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUnknownUser
+	}
 	if err != nil {
 		// Log the specific error for debugging (without exposing it to the user)
 		log.Printf("Database error during authentication for user '%s': %v", username, err)
-		return nil, errors.New("invalid username or password")
+		return nil, err
+	}
+
+	// Directory users are mirrored locally, but their password lives in the directory
+	if externalID != nil && strings.HasPrefix(*externalID, ldapExternalPrefix) {
+		return nil, ErrUnknownUser
 	}
 
-	if user.Password != password {
+	if !CheckPassword(user.Password, password) {
 		log.Printf("Password mismatch for user '%s'", username)
-		return nil, errors.New("invalid username or password")
+		return nil, ErrInvalidCredentials
 	}
 
-	log.Printf("User '%s' authenticated successfully", username)
 	return &user, nil
 }
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+	"tms-server/config"
+	"tms-server/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyTTL is how long a response is replayed for its key
+func IdempotencyTTL() time.Duration {
+	return config.GetEnvAsDuration("IDEMPOTENCY_TTL", "24h")
+}
+
+// IdempotencyClaimTimeout is how long a key stays in progress. A claim left
+// behind by a request that never finished, e.g. because the server stopped,
+// expires after it instead of blocking retries for the whole TTL.
+func IdempotencyClaimTimeout() time.Duration {
+	return config.GetEnvAsDuration("IDEMPOTENCY_CLAIM_TIMEOUT", "5m")
+}
+
+// ClaimIdempotencyKey records that a request with the key has started. It
+// returns the ID of the claim if this request claimed the key, or the record
+// of an earlier request with the same key within the TTL.
+func ClaimIdempotencyKey(ctx context.Context, userID uint, key, requestHash string) (uint, *models.IdempotencyKey, error) {
+	db := config.DB.WithContext(ctx)
+
+	// An expired key, or an abandoned claim, is free to be used again
+	now := time.Now()
+	err := db.Where("user_id = ? AND key = ? AND (created_at < ? OR (status = 0 AND created_at < ?))",
+		userID, key, now.Add(-IdempotencyTTL()), now.Add(-IdempotencyClaimTimeout())).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	claim := models.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&claim)
+	if result.Error != nil {
+		return 0, nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return claim.ID, nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		return 0, nil, err
+	}
+	return 0, &existing, nil
+}
+
+// SaveIdempotentResponse stores the response to replay for a claim. A claim
+// that expired meanwhile, and may have been taken by a retry, is left alone.
+func SaveIdempotentResponse(ctx context.Context, claimID uint, status int, body []byte, etag string) {
+	err := config.DB.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("id = ?", claimID).
+		Updates(map[string]any{"status": status, "body": body, "etag": etag}).Error
+	if err != nil {
+		log.Printf("Failed to store response for idempotency key %d: %v", claimID, err)
+	}
+}
+
+// ReleaseIdempotencyKey forgets a claim whose request failed, so it can be
+// retried
+func ReleaseIdempotencyKey(ctx context.Context, claimID uint) {
+	err := config.DB.WithContext(ctx).Where("id = ?", claimID).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		log.Printf("Failed to release idempotency key %d: %v", claimID, err)
+	}
+}
+
+// StartIdempotencyPurger deletes expired idempotency keys of all tenants
+// every hour
+func StartIdempotencyPurger() {
+	go func() {
+		for {
+			cutoff := time.Now().Add(-IdempotencyTTL())
+			if err := config.DB.Where("created_at < ?", cutoff).Delete(&models.IdempotencyKey{}).Error; err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"tms-server/config"
+	"tms-server/models"
+)
+
+// ImpersonationActive reports whether the impersonation has neither been
+// stopped nor expired. Tokens can't be revoked, so this is checked on every
+// request made with an impersonation token.
+func ImpersonationActive(ctx context.Context, impersonationID uint) bool {
+	var impersonation models.Impersonation
+	if err := config.DB.WithContext(ctx).First(&impersonation, impersonationID).Error; err != nil {
+		return false
+	}
+	return impersonation.Active()
+}
+
+// RecordImpersonationAction logs a request made during an impersonation
+func RecordImpersonationAction(ctx context.Context, impersonationID uint, method, path string, status int) {
+	action := models.ImpersonationAction{
+		ImpersonationID: impersonationID,
+		Method:          method,
+		Path:            path,
+		Status:          status,
+	}
+	if err := config.DB.WithContext(ctx).Create(&action).Error; err != nil {
+		log.Printf("Failed to record action of impersonation %d: %v", impersonationID, err)
+	}
+}
@@ -4,22 +4,39 @@ import (
 	"errors"
 	"os"
 	"time"
+	"tms-server/models"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 const TokenExpiry = 24 * 7 * time.Hour
 
+// MFATokenExpiry is how long a user has to enter their second factor
+const MFATokenExpiry = 5 * time.Minute
+
+const PurposeMFA = "mfa"
+
 type CustomClaims struct {
+	UserID   uint   `json:"user_id"`
+	TenantID uint   `json:"tenant_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Purpose  string `json:"purpose,omitempty"` // empty for auth tokens
+
+	// Set on tokens of an impersonation, identifying the real user
+	ImpersonationID uint   `json:"impersonation_id,omitempty"`
+	ImpersonatorID  uint   `json:"impersonator_id,omitempty"`
+	Impersonator    string `json:"impersonator,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(username, role string) (string, error) {
+func GenerateToken(user *models.User) (string, error) {
 	claims := &CustomClaims{
-		Username: username,
-		Role:     role,
+		UserID:   user.ID,
+		TenantID: user.TenantID,
+		Username: user.Username,
+		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -30,7 +47,69 @@ func GenerateToken(username, role string) (string, error) {
 	return token.SignedString([]byte(os.Getenv("APP_JWT_SECRET")))
 }
 
+// GenerateImpersonationToken issues an auth token for the impersonated user
+// that also names the real user and expires with the impersonation
+func GenerateImpersonationToken(target *models.User, impersonation *models.Impersonation) (string, error) {
+	claims := &CustomClaims{
+		UserID:          target.ID,
+		TenantID:        target.TenantID,
+		Username:        target.Username,
+		Role:            target.Role,
+		ImpersonationID: impersonation.ID,
+		ImpersonatorID:  impersonation.ActorID,
+		Impersonator:    impersonation.ActorUsername,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(impersonation.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("APP_JWT_SECRET")))
+}
+
+// GenerateMFAToken issues a short-lived token proving the password step of a
+// two-step login. It is not accepted as an auth token.
+func GenerateMFAToken(user *models.User) (string, error) {
+	claims := &CustomClaims{
+		UserID:   user.ID,
+		TenantID: user.TenantID,
+		Username: user.Username,
+		Role:     user.Role,
+		Purpose:  PurposeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFATokenExpiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("APP_JWT_SECRET")))
+}
+
+func ValidateMFAToken(tokenString string) (*CustomClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeMFA {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
 func ValidateToken(tokenString string) (*CustomClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func parseToken(tokenString string) (*CustomClaims, error) {
 	claims := &CustomClaims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
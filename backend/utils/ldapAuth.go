@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"tms-server/config"
+	"tms-server/models"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// Users authenticated by the directory are mirrored into the users table with
+// an external_id of "ldap|<dn>"
+const ldapExternalPrefix = "ldap|"
+
+// Roles in order of increasing privilege, used when a user is in several groups
+var roleRank = []string{"faculty", "admin", "superadmin"}
+
+// LDAPConn is the part of *ldap.Conn the authenticator uses, so tests can
+// plug in a stub directory
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(*ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPAuthenticator binds against a directory and maps groups to roles
+type LDAPAuthenticator struct {
+	Dial func() (LDAPConn, error)
+
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	UserFilter   string // e.g. (uid=%s), %s is the escaped username
+	GroupAttr    string // attribute on the user entry listing group DNs
+
+	// RoleGroups maps a role to the group DNs that grant it
+	RoleGroups map[string][]string
+	// DefaultRole is given to users in none of RoleGroups; empty denies them
+	DefaultRole string
+
+	// Mirror keeps the local copy of an authenticated directory user, and
+	// defaults to mirrorLDAPUser
+	Mirror func(ctx context.Context, username, dn, role string) (*models.User, error)
+}
+
+func NewLDAPAuthenticatorFromEnv() *LDAPAuthenticator {
+	url := os.Getenv("LDAP_URL")
+	startTLS := os.Getenv("LDAP_START_TLS") == "true"
+
+	defaultRole, ok := os.LookupEnv("LDAP_DEFAULT_ROLE")
+	if !ok {
+		defaultRole = "faculty"
+	}
+
+	return &LDAPAuthenticator{
+		Dial: func() (LDAPConn, error) {
+			conn, err := ldap.DialURL(url, ldap.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}))
+			if err != nil {
+				return nil, err
+			}
+			if startTLS {
+				if err := conn.StartTLS(&tls.Config{ServerName: hostOf(url)}); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			return conn, nil
+		},
+		BindDN:       os.Getenv("LDAP_BIND_DN"),
+		BindPassword: os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:       os.Getenv("LDAP_BASE_DN"),
+		UserFilter:   envOr("LDAP_USER_FILTER", "(uid=%s)"),
+		GroupAttr:    envOr("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		RoleGroups:   parseRoleGroups(os.Getenv("LDAP_ROLE_GROUPS")),
+		DefaultRole:  defaultRole,
+	}
+}
+
+func (a *LDAPAuthenticator) Name() string { return "ldap" }
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which many servers accept
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.Dial()
+	if err != nil {
+		return nil, fmt.Errorf("ldap dial: %w", err)
+	}
+	defer conn.Close()
+
+	if a.BindDN != "" {
+		if err := conn.Bind(a.BindDN, a.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service bind: %w", err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 5, false,
+		fmt.Sprintf(a.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", a.GroupAttr},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap search: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrUnknownUser
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap user bind: %w", err)
+	}
+
+	role := a.roleFor(entry.GetAttributeValues(a.GroupAttr))
+	if role == "" {
+		log.Printf("LDAP user '%s' is in no group mapped to a role", username)
+		return nil, ErrInvalidCredentials
+	}
+
+	mirror := a.Mirror
+	if mirror == nil {
+		mirror = mirrorLDAPUser
+	}
+	return mirror(ctx, username, entry.DN, role)
+}
+
+// roleFor returns the most privileged role granted by any of the groups
+func (a *LDAPAuthenticator) roleFor(groups []string) string {
+	best := -1
+	for role, roleGroups := range a.RoleGroups {
+		rank := slices.Index(roleRank, role)
+		if rank <= best {
+			continue
+		}
+		for _, g := range groups {
+			if slices.ContainsFunc(roleGroups, func(rg string) bool { return strings.EqualFold(rg, g) }) {
+				best = rank
+				break
+			}
+		}
+	}
+	if best < 0 {
+		return a.DefaultRole
+	}
+	return roleRank[best]
+}
+
+// mirrorLDAPUser creates or updates the local copy of a directory user so the
+// rest of the app can look them up by username. The role always follows the
+// directory.
+func mirrorLDAPUser(ctx context.Context, username, dn, role string) (*models.User, error) {
+	externalID := ldapExternalPrefix + dn
+
+	var user models.User
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("username = ?", username).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Directory users never log in with a local password
+			password, err := RandomString(32)
+			if err != nil {
+				return err
+			}
+			hash, err := HashPassword(password)
+			if err != nil {
+				return err
+			}
+			user = models.User{Username: username, Password: hash, Role: role, ExternalID: &externalID}
+			log.Printf("Creating local user '%s' for LDAP entry %s", username, dn)
+			return tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checkLDAPLink(&user, dn); err != nil {
+			return err
+		}
+
+		if user.Role != role {
+			log.Printf("Updating role of LDAP user '%s' from %s to %s", username, user.Role, role)
+			user.Role = role
+			return tx.Model(&user).Update("role", role).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// checkLDAPLink never lets a directory entry take over an existing local
+// account, e.g. an admin created before LDAP was enabled whose username also
+// exists in the directory. The chain then moves on to the local password.
+func checkLDAPLink(user *models.User, dn string) error {
+	if user.ExternalID == nil || *user.ExternalID != ldapExternalPrefix+dn {
+		log.Printf("LDAP entry %s matches local user '%s' that is not linked to it", dn, user.Username)
+		return ErrUnknownUser
+	}
+	return nil
+}
+
+// parseRoleGroups parses "admin=cn=a,dc=x;admin=cn=b,dc=x;superadmin=cn=c,dc=x"
+func parseRoleGroups(s string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, item := range strings.Split(s, ";") {
+		role, dn, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || dn == "" {
+			continue
+		}
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !slices.Contains(roleRank, role) {
+			log.Printf("Ignoring unknown role '%s' in LDAP_ROLE_GROUPS", role)
+			continue
+		}
+		groups[role] = append(groups[role], strings.TrimSpace(dn))
+	}
+	return groups
+}
+
+func hostOf(url string) string {
+	_, rest, _ := strings.Cut(url, "://")
+	host, _, _ := strings.Cut(rest, ":")
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
+
+func envOr(name, defaultVal string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return defaultVal
+}
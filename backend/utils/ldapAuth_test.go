@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"tms-server/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeDirectory is an in-process LDAP server holding entries by uid
+type fakeDirectory struct {
+	entries map[string]fakeEntry // by uid
+	service string               // password of the service account
+	down    bool                 // dialing fails
+	binds   []string             // DNs bound as, in order
+}
+
+type fakeEntry struct {
+	password string
+	groups   []string
+}
+
+func (d *fakeDirectory) dn(uid string) string { return "uid=" + uid + ",ou=people,dc=uni,dc=edu" }
+
+func (d *fakeDirectory) Dial() (LDAPConn, error) {
+	if d.down {
+		return nil, errors.New("connection refused")
+	}
+	return &fakeConn{d}, nil
+}
+
+type fakeConn struct{ dir *fakeDirectory }
+
+func (c *fakeConn) Bind(dn, password string) error {
+	c.dir.binds = append(c.dir.binds, dn)
+	if dn == "cn=tms,dc=uni,dc=edu" && password == c.dir.service {
+		return nil
+	}
+	for uid, entry := range c.dir.entries {
+		if c.dir.dn(uid) == dn && entry.password == password {
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+	for uid, entry := range c.dir.entries {
+		if req.Filter == fmt.Sprintf("(uid=%s)", ldap.EscapeFilter(uid)) {
+			result.Entries = append(result.Entries, ldap.NewEntry(c.dir.dn(uid), map[string][]string{"memberOf": entry.groups}))
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+// fakeUsers stands in for the users table, applying the same link rule as
+// mirrorLDAPUser to accounts that already exist
+type fakeUsers map[string]*models.User
+
+func (u fakeUsers) mirror(ctx context.Context, username, dn, role string) (*models.User, error) {
+	user, ok := u[username]
+	if !ok {
+		externalID := ldapExternalPrefix + dn
+		user = &models.User{Username: username, Role: role, ExternalID: &externalID}
+		u[username] = user
+		return user, nil
+	}
+	if err := checkLDAPLink(user, dn); err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+const (
+	adminGroup      = "cn=tms-admins,ou=groups,dc=uni,dc=edu"
+	superadminGroup = "cn=tms-superadmins,ou=groups,dc=uni,dc=edu"
+)
+
+func newTestLDAP(dir *fakeDirectory, users fakeUsers) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		Dial:         dir.Dial,
+		BindDN:       "cn=tms,dc=uni,dc=edu",
+		BindPassword: dir.service,
+		BaseDN:       "dc=uni,dc=edu",
+		UserFilter:   "(uid=%s)",
+		GroupAttr:    "memberOf",
+		RoleGroups:   parseRoleGroups("admin=" + adminGroup + ";superadmin=" + superadminGroup),
+		DefaultRole:  "faculty",
+		Mirror:       users.mirror,
+	}
+}
+
+func newTestDirectory() *fakeDirectory {
+	return &fakeDirectory{
+		service: "service-secret",
+		entries: map[string]fakeEntry{
+			"jane":  {password: "jane-pw"},
+			"amir":  {password: "amir-pw", groups: []string{"CN=TMS-Admins,OU=Groups,DC=uni,DC=edu"}},
+			"root":  {password: "root-pw", groups: []string{adminGroup, superadminGroup}},
+			"guest": {password: "guest-pw", groups: []string{"cn=visitors,dc=uni,dc=edu"}},
+		},
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	tests := []struct {
+		name, username, password string
+		defaultRole              string
+		wantRole                 string
+		wantErr                  error
+	}{
+		{"bind success, default role", "jane", "jane-pw", "faculty", "faculty", nil},
+		{"group maps to role regardless of case", "amir", "amir-pw", "faculty", "admin", nil},
+		{"most privileged group wins", "root", "root-pw", "faculty", "superadmin", nil},
+		{"unmapped group gets the default role", "guest", "guest-pw", "faculty", "faculty", nil},
+		{"unmapped group denied without a default role", "guest", "guest-pw", "", "", ErrInvalidCredentials},
+		{"wrong password", "jane", "nope", "faculty", "", ErrInvalidCredentials},
+		{"empty password is never an anonymous bind", "jane", "", "faculty", "", ErrInvalidCredentials},
+		{"unknown user", "nobody", "pw", "faculty", "", ErrUnknownUser},
+		{"filter injection is escaped", "*", "pw", "faculty", "", ErrUnknownUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := newTestLDAP(newTestDirectory(), fakeUsers{})
+			auth.DefaultRole = tt.defaultRole
+
+			user, err := auth.Authenticate(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Role != tt.wantRole {
+				t.Errorf("role = %s, want %s", user.Role, tt.wantRole)
+			}
+			if want := ldapExternalPrefix + "uid=" + tt.username + ",ou=people,dc=uni,dc=edu"; user.ExternalID == nil || *user.ExternalID != want {
+				t.Errorf("external ID = %v, want %s", user.ExternalID, want)
+			}
+		})
+	}
+}
+
+func TestLDAPServiceBindFailure(t *testing.T) {
+	dir := newTestDirectory()
+	auth := newTestLDAP(dir, fakeUsers{})
+	auth.BindPassword = "wrong"
+
+	_, err := auth.Authenticate(context.Background(), "jane", "jane-pw")
+	// A misconfigured service account is an outage, not a wrong password
+	if err == nil || errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("err = %v, want a service bind error", err)
+	}
+	if len(dir.binds) != 1 {
+		t.Errorf("bound %d times, want only the service bind", len(dir.binds))
+	}
+}
+
+func TestLDAPRoleFollowsDirectory(t *testing.T) {
+	dir := newTestDirectory()
+	users := fakeUsers{}
+	auth := newTestLDAP(dir, users)
+
+	if _, err := auth.Authenticate(context.Background(), "amir", "amir-pw"); err != nil {
+		t.Fatal(err)
+	}
+	dir.entries["amir"] = fakeEntry{password: "amir-pw"} // removed from the admin group
+	user, err := auth.Authenticate(context.Background(), "amir", "amir-pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != "faculty" {
+		t.Errorf("role = %s, want faculty after leaving the admin group", user.Role)
+	}
+}
+
+// stubLocal is the local authenticator with fixed accounts
+type stubLocal map[string]string
+
+func (stubLocal) Name() string { return "local" }
+
+func (s stubLocal) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	stored, ok := s[username]
+	if !ok {
+		return nil, ErrUnknownUser
+	}
+	if stored != password {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.User{Username: username, Role: "superadmin"}, nil
+}
+
+func TestLDAPChain(t *testing.T) {
+	original := Authenticators
+	t.Cleanup(func() { Authenticators = original })
+	authenticatorsOnce.Do(func() {}) // keep AUTH_BACKENDS out of it
+
+	local := stubLocal{"admin": "local-pw", "jane": "old-local-pw"}
+
+	tests := []struct {
+		name, username, password string
+		dir                      func(*fakeDirectory)
+		users                    fakeUsers
+		wantErr                  error
+		wantExternal             bool // authenticated by the directory
+	}{
+		{name: "directory user", username: "jane", password: "jane-pw", wantExternal: true},
+		{name: "not in the directory falls through to local", username: "admin", password: "local-pw"},
+		{
+			name: "directory down falls through to local", username: "admin", password: "local-pw",
+			dir: func(d *fakeDirectory) { d.down = true },
+		},
+		{
+			name: "wrong directory password doesn't try local", username: "jane", password: "old-local-pw",
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			// An admin created before LDAP was enabled, whose username is also
+			// in the directory: the directory password must not open it
+			name: "unlinked local account is not taken over", username: "admin", password: "admin-dir-pw",
+			dir:     func(d *fakeDirectory) { d.entries["admin"] = fakeEntry{password: "admin-dir-pw"} },
+			users:   fakeUsers{"admin": {Username: "admin", Role: "superadmin"}},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "unlinked local account keeps its local password", username: "admin", password: "local-pw",
+			dir:   func(d *fakeDirectory) { d.entries["admin"] = fakeEntry{password: "local-pw"} },
+			users: fakeUsers{"admin": {Username: "admin", Role: "superadmin"}},
+		},
+		{
+			name: "account linked to another entry is not taken over", username: "jane", password: "jane-pw",
+			users:   fakeUsers{"jane": {Username: "jane", Role: "admin", ExternalID: ptr(ldapExternalPrefix + "uid=jane,ou=former,dc=uni,dc=edu")}},
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestDirectory()
+			if tt.dir != nil {
+				tt.dir(dir)
+			}
+			users := tt.users
+			if users == nil {
+				users = fakeUsers{}
+			}
+			Authenticators = []Authenticator{newTestLDAP(dir, users), local}
+
+			user, err := AuthenticateUser(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if external := user.ExternalID != nil; external != tt.wantExternal {
+				t.Errorf("authenticated by the directory = %v, want %v", external, tt.wantExternal)
+			}
+		})
+	}
+}
+
+func TestParseRoleGroups(t *testing.T) {
+	groups := parseRoleGroups(" Admin=cn=a,dc=x ; admin=cn=b,dc=x;superadmin=cn=c,dc=x;janitor=cn=d,dc=x;broken")
+	if len(groups["admin"]) != 2 || len(groups["superadmin"]) != 1 || len(groups) != 2 {
+		t.Errorf("groups = %v", groups)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
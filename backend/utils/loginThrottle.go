@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tms-server/config"
+)
+
+// LoginThrottle tracks failed login attempts per username and per IP and
+// locks a key out with exponential backoff once it crosses its threshold.
+// Usernames are only unique within a tenant, so they are keyed by tenant too.
+type LoginThrottle struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+
+	MaxUserAttempts int
+	MaxIPAttempts   int
+	BaseLockout     time.Duration
+	MaxLockout      time.Duration
+	Window          time.Duration
+
+	// Now is the clock, time.Now unless a test sets it
+	Now func() time.Time
+}
+
+type throttleEntry struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Lockout describes a currently locked username or IP.
+type Lockout struct {
+	Kind        string    `json:"kind"` // "username", "ip" or "code" (a user ID)
+	Value       string    `json:"value"`
+	TenantID    uint      `json:"-"` // zero for IPs, which are shared by all tenants
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+var (
+	throttle     *LoginThrottle
+	throttleOnce sync.Once
+)
+
+// Throttle returns the process-wide login throttle. It is created lazily so
+// that the .env file has been loaded before its settings are read.
+func Throttle() *LoginThrottle {
+	throttleOnce.Do(func() { throttle = NewLoginThrottle() })
+	return throttle
+}
+
+func NewLoginThrottle() *LoginThrottle {
+	return &LoginThrottle{
+		entries:         make(map[string]*throttleEntry),
+		MaxUserAttempts: config.GetEnvAsInt("LOGIN_MAX_ATTEMPTS", 5),
+		MaxIPAttempts:   config.GetEnvAsInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+		BaseLockout:     config.GetEnvAsDuration("LOGIN_LOCKOUT_BASE", "1m"),
+		MaxLockout:      config.GetEnvAsDuration("LOGIN_LOCKOUT_MAX", "1h"),
+		Window:          config.GetEnvAsDuration("LOGIN_ATTEMPT_WINDOW", "15m"),
+	}
+}
+
+func userKey(tenantID uint, username string) string {
+	return "username:" + strconv.FormatUint(uint64(tenantID), 10) + ":" + username
+}
+func ipKey(ip string) string { return "ip:" + ip }
+
+// codeKey counts wrong second-factor codes entered by a signed-in user
+func codeKey(tenantID, userID uint) string {
+	return "code:" + strconv.FormatUint(uint64(tenantID), 10) + ":" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// Check returns how long the caller has to wait before trying again, or zero
+// if neither the username nor the IP is locked.
+func (t *LoginThrottle) Check(tenantID uint, username, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var wait time.Duration
+	for _, key := range []string{userKey(tenantID, username), ipKey(ip)} {
+		e := t.entry(key, now)
+		if e != nil && e.LockedUntil.After(now) {
+			wait = max(wait, e.LockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// Fail records a failed attempt and returns the resulting lockout duration,
+// or zero if neither key has crossed its threshold yet.
+func (t *LoginThrottle) Fail(tenantID uint, username, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	wait := t.fail(userKey(tenantID, username), t.MaxUserAttempts, now)
+	wait = max(wait, t.fail(ipKey(ip), t.MaxIPAttempts, now))
+	return wait
+}
+
+// Succeed clears the failure counter for the username. The IP's counter is
+// left to expire with the window, or one attacker could spray guesses over
+// many usernames and log in to their own account every few tries to reset it.
+func (t *LoginThrottle) Succeed(tenantID uint, username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, userKey(tenantID, username))
+}
+
+// CheckCode returns how long a signed-in user has to wait before entering a
+// second-factor code again, or zero if they aren't locked out. Codes are
+// throttled by user ID, as the session, not the IP, is what is guessing.
+func (t *LoginThrottle) CheckCode(tenantID, userID uint) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if e := t.entry(codeKey(tenantID, userID), now); e != nil && e.LockedUntil.After(now) {
+		return e.LockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// FailCode records a wrong code and returns the resulting lockout, with the
+// same threshold and backoff as a username
+func (t *LoginThrottle) FailCode(tenantID, userID uint) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.fail(codeKey(tenantID, userID), t.MaxUserAttempts, t.now())
+}
+
+// SucceedCode clears the user's wrong codes
+func (t *LoginThrottle) SucceedCode(tenantID, userID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, codeKey(tenantID, userID))
+}
+
+// Unlock clears any lockout on the given username and/or IP.
+func (t *LoginThrottle) Unlock(tenantID uint, username, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if username != "" {
+		delete(t.entries, userKey(tenantID, username))
+	}
+	if ip != "" {
+		delete(t.entries, ipKey(ip))
+	}
+}
+
+// Lockouts lists the tenant's usernames and all IPs that are currently locked.
+func (t *LoginThrottle) Lockouts(tenantID uint) []Lockout {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	lockouts := []Lockout{}
+	for key := range t.entries {
+		e := t.entry(key, now)
+		if e == nil || !e.LockedUntil.After(now) {
+			continue
+		}
+		lockout := splitKey(key)
+		if lockout.Kind != "ip" && lockout.TenantID != tenantID {
+			continue
+		}
+		lockout.Failures = e.Failures
+		lockout.LockedUntil = e.LockedUntil
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts
+}
+
+func (t *LoginThrottle) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+// entry returns the entry for key, dropping it if it has gone stale.
+func (t *LoginThrottle) entry(key string, now time.Time) *throttleEntry {
+	e, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	if now.Sub(e.LastFailure) > t.Window && !e.LockedUntil.After(now) {
+		delete(t.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (t *LoginThrottle) fail(key string, threshold int, now time.Time) time.Duration {
+	e := t.entry(key, now)
+	if e == nil {
+		e = &throttleEntry{}
+		t.entries[key] = e
+	}
+	e.Failures++
+	e.LastFailure = now
+
+	if e.Failures < threshold {
+		return 0
+	}
+
+	// Double the lockout for every failure past the threshold
+	lockout := t.BaseLockout
+	for i := threshold; i < e.Failures && lockout < t.MaxLockout; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, t.MaxLockout)
+	e.LockedUntil = now.Add(lockout)
+	return lockout
+}
+
+func splitKey(key string) Lockout {
+	kind, value, _ := strings.Cut(key, ":")
+	if kind == "ip" {
+		return Lockout{Kind: kind, Value: value}
+	}
+	tenant, name, _ := strings.Cut(value, ":")
+	tenantID, _ := strconv.ParseUint(tenant, 10, 64)
+	return Lockout{Kind: kind, Value: name, TenantID: uint(tenantID)}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// throttleStep advances the clock, acts on the throttle and expects the
+// lockout the action returns; check, succeed and unlock are followed by a
+// Check of the same username and IP
+type throttleStep struct {
+	advance  time.Duration
+	action   string // "fail", "check", "succeed" or "unlock"
+	username string
+	ip       string
+	want     time.Duration
+}
+
+func TestLoginThrottle(t *testing.T) {
+	fail := func(username, ip string, want time.Duration) throttleStep {
+		return throttleStep{action: "fail", username: username, ip: ip, want: want}
+	}
+
+	tests := []struct {
+		name  string
+		steps []throttleStep
+	}{
+		{"locks at the threshold", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", time.Minute),
+			{action: "check", username: "jane", ip: "10.0.0.2", want: time.Minute},
+			{action: "check", username: "john", ip: "10.0.0.2", want: 0},
+		}},
+		{"doubles the lockout up to the cap", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", time.Minute),
+			fail("jane", "10.0.0.1", 2*time.Minute),
+			fail("jane", "10.0.0.1", 4*time.Minute),
+			fail("jane", "10.0.0.1", 5*time.Minute),
+		}},
+		{"unlocks when the lockout ends", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", time.Minute),
+			{advance: 30 * time.Second, action: "check", username: "jane", want: 30 * time.Second},
+			{advance: 30 * time.Second, action: "check", username: "jane", want: 0},
+		}},
+		{"unlocks by hand", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", time.Minute),
+			{action: "unlock", username: "jane", want: 0},
+		}},
+		{"failures within the window add up", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			{advance: 14 * time.Minute, action: "fail", username: "jane", ip: "10.0.0.1", want: time.Minute},
+		}},
+		{"failures decay after the window", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			{advance: 16 * time.Minute, action: "fail", username: "jane", ip: "10.0.0.1", want: 0},
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", time.Minute),
+		}},
+		{"success clears the username", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("jane", "10.0.0.1", 0),
+			{action: "succeed", username: "jane", want: 0},
+			fail("jane", "10.0.0.2", 0),
+			fail("jane", "10.0.0.2", 0),
+		}},
+		{"success keeps the IP's failures", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("john", "10.0.0.1", 0),
+			{action: "succeed", username: "mallory", ip: "10.0.0.1", want: 0},
+			fail("jack", "10.0.0.1", 0),
+			fail("jill", "10.0.0.1", time.Minute),
+			{action: "check", username: "mallory", ip: "10.0.0.1", want: time.Minute},
+		}},
+		{"the IP's failures decay after a success", []throttleStep{
+			fail("jane", "10.0.0.1", 0),
+			fail("john", "10.0.0.1", 0),
+			fail("jack", "10.0.0.1", 0),
+			{action: "succeed", username: "mallory", ip: "10.0.0.1", want: 0},
+			{advance: 16 * time.Minute, action: "fail", username: "jill", ip: "10.0.0.1", want: 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+			throttle := &LoginThrottle{
+				entries:         make(map[string]*throttleEntry),
+				MaxUserAttempts: 3,
+				MaxIPAttempts:   4,
+				BaseLockout:     time.Minute,
+				MaxLockout:      5 * time.Minute,
+				Window:          15 * time.Minute,
+				Now:             func() time.Time { return clock },
+			}
+
+			for i, step := range tt.steps {
+				clock = clock.Add(step.advance)
+				var got time.Duration
+				switch step.action {
+				case "fail":
+					got = throttle.Fail(1, step.username, step.ip)
+				case "succeed":
+					throttle.Succeed(1, step.username)
+				case "unlock":
+					throttle.Unlock(1, step.username, step.ip)
+				}
+				if step.action != "fail" {
+					got = throttle.Check(1, step.username, step.ip)
+				}
+				if got != step.want {
+					t.Fatalf("step %d (%s %s from %s): got %s, want %s", i+1, step.action, step.username, step.ip, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCodeThrottle(t *testing.T) {
+	throttle := &LoginThrottle{
+		entries:         make(map[string]*throttleEntry),
+		MaxUserAttempts: 3,
+		BaseLockout:     time.Minute,
+		MaxLockout:      time.Hour,
+		Window:          15 * time.Minute,
+	}
+
+	for i := range 2 {
+		if wait := throttle.FailCode(1, 7); wait != 0 {
+			t.Fatalf("failure %d: locked for %s", i+1, wait)
+		}
+	}
+	if wait := throttle.FailCode(1, 7); wait != time.Minute {
+		t.Fatalf("third failure: locked for %s, want 1m", wait)
+	}
+	if throttle.CheckCode(1, 7) == 0 {
+		t.Error("user 7 not locked")
+	}
+	if throttle.CheckCode(2, 7) != 0 || throttle.CheckCode(1, 8) != 0 {
+		t.Error("lockout leaked to another tenant or user")
+	}
+
+	lockouts := throttle.Lockouts(1)
+	if len(lockouts) != 1 || lockouts[0].Kind != "code" || lockouts[0].Value != "7" {
+		t.Errorf("lockouts = %+v, want user 7's codes", lockouts)
+	}
+	if len(throttle.Lockouts(2)) != 0 {
+		t.Error("lockout listed for another tenant")
+	}
+
+	throttle.SucceedCode(1, 7)
+	if throttle.CheckCode(1, 7) != 0 {
+		t.Error("still locked after clearing")
+	}
+}
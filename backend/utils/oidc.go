@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"tms-server/models"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCClient wraps the discovered provider and OAuth2 config for SSO login
+type OIDCClient struct {
+	Provider *oidc.Provider
+	Verifier *oidc.IDTokenVerifier
+	OAuth2   oauth2.Config
+
+	// Email domains whose users are auto-provisioned as faculty
+	ProvisionDomains []string
+	// Where the browser is sent after a successful login
+	FrontendRedirectURL string
+}
+
+// OIDCIdentity is what we take from a verified ID token
+type OIDCIdentity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+var ErrOIDCNotConfigured = errors.New("OIDC login is not configured")
+
+// ErrOIDCUsernameTaken is returned when a local account already holds the
+// username an identity would be provisioned with
+var ErrOIDCUsernameTaken = errors.New("an account with this username already exists, sign in with your password and link this identity from your account first")
+
+var (
+	oidcClient *OIDCClient
+	oidcMu     sync.Mutex
+)
+
+// OIDC returns the OIDC client, running provider discovery on first use.
+// Discovery is retried on later calls if it fails, so a provider that is down
+// at startup doesn't disable SSO until a restart.
+func OIDC(ctx context.Context) (*OIDCClient, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcClient != nil {
+		return oidcClient, nil
+	}
+
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if issuer == "" || clientID == "" {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+	if envScopes := os.Getenv("OIDC_SCOPES"); envScopes != "" {
+		scopes = splitList(envScopes)
+	}
+
+	oidcClient = &OIDCClient{
+		Provider: provider,
+		Verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		OAuth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		ProvisionDomains:    splitList(os.Getenv("OIDC_AUTO_PROVISION_DOMAINS")),
+		FrontendRedirectURL: os.Getenv("OIDC_FRONTEND_REDIRECT_URL"),
+	}
+	return oidcClient, nil
+}
+
+// Exchange trades the authorization code for tokens and verifies the ID token
+func (o *OIDCClient) Exchange(ctx context.Context, code, verifier, nonce string) (*OIDCIdentity, error) {
+	token, err := o.OAuth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id_token in token response")
+	}
+
+	idToken, err := o.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     *bool  `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &OIDCIdentity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   strings.ToLower(claims.Email),
+		// An address the provider doesn't vouch for could be anyone's
+		EmailVerified:     claims.EmailVerified != nil && *claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// CanProvision reports whether a user with this email may be auto-provisioned
+func (o *OIDCClient) CanProvision(email string) bool {
+	_, domain, ok := strings.Cut(email, "@")
+	return ok && slices.Contains(o.ProvisionDomains, strings.ToLower(domain))
+}
+
+// ExternalID is the key an identity is linked to a user by
+func (i *OIDCIdentity) ExternalID() string {
+	return i.Issuer + "|" + i.Subject
+}
+
+// NewUser builds the user auto-provisioned for an identity, named after its
+// email. taken reports whether a username is in use; the local account
+// holding it may not be the identity's, so it is neither linked nor named
+// around and ErrOIDCUsernameTaken is returned instead.
+func (o *OIDCClient) NewUser(identity *OIDCIdentity, role string, taken func(username string) (bool, error)) (*models.User, error) {
+	exists, err := taken(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrOIDCUsernameTaken
+	}
+
+	// SSO users never log in with a password, so give them an unguessable one
+	password, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	externalID := identity.ExternalID()
+	return &models.User{
+		Username:   identity.Email,
+		Password:   hash,
+		Role:       role,
+		Email:      &identity.Email,
+		ExternalID: &externalID,
+	}, nil
+}
+
+// RandomString returns n random bytes hex-encoded, for OAuth state and nonces
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, strings.ToLower(item))
+		}
+	}
+	return list
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is an OpenID provider with discovery, a token endpoint and a
+// JWKS, answering every code with an ID token carrying claims
+type mockProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, p.claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(p.key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     idToken,
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// idClaims are the claims of a valid ID token for the test client
+func (p *mockProvider) idClaims(extra jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":   p.URL,
+		"sub":   "jane",
+		"aud":   "tms",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": "nonce",
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return claims
+}
+
+func mockOIDC(t *testing.T, p *mockProvider) *OIDCClient {
+	t.Helper()
+	t.Setenv("OIDC_ISSUER_URL", p.URL)
+	t.Setenv("OIDC_CLIENT_ID", "tms")
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_AUTO_PROVISION_DOMAINS", "Example.edu")
+
+	oidcMu.Lock()
+	oidcClient = nil
+	oidcMu.Unlock()
+	t.Cleanup(func() { oidcClient = nil })
+
+	client, err := OIDC(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestOIDCExchange(t *testing.T) {
+	p := newMockProvider(t)
+	client := mockOIDC(t, p)
+
+	tests := []struct {
+		name     string
+		code     string
+		nonce    string
+		claims   jwt.MapClaims
+		wantErr  bool
+		verified bool
+	}{
+		{"verified email", "good-code", "nonce", p.idClaims(jwt.MapClaims{"email": "Jane@Example.edu", "email_verified": true}), false, true},
+		{"unverified email", "good-code", "nonce", p.idClaims(jwt.MapClaims{"email": "jane@example.edu", "email_verified": false}), false, false},
+		{"missing email_verified", "good-code", "nonce", p.idClaims(jwt.MapClaims{"email": "jane@example.edu"}), false, false},
+		{"nonce mismatch", "good-code", "other", p.idClaims(nil), true, false},
+		{"wrong audience", "good-code", "nonce", p.idClaims(jwt.MapClaims{"aud": "someone-else"}), true, false},
+		{"expired", "good-code", "nonce", p.idClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), true, false},
+		{"bad code", "bad-code", "nonce", p.idClaims(nil), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.claims = tt.claims
+			identity, err := client.Exchange(context.Background(), tt.code, "verifier", tt.nonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Issuer != p.URL || identity.Subject != "jane" {
+				t.Errorf("identity = %s|%s, want %s|jane", identity.Issuer, identity.Subject, p.URL)
+			}
+			if identity.Email != "jane@example.edu" {
+				t.Errorf("email = %q, want it lowercased", identity.Email)
+			}
+			if identity.EmailVerified != tt.verified {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.verified)
+			}
+		})
+	}
+}
+
+func TestOIDCRejectsForeignKey(t *testing.T) {
+	p := newMockProvider(t)
+	client := mockOIDC(t, p)
+
+	// An ID token signed by a key the provider's JWKS doesn't list
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.key = other
+	p.claims = p.idClaims(jwt.MapClaims{"email": "jane@example.edu", "email_verified": true})
+
+	if _, err := client.Exchange(context.Background(), "good-code", "verifier", "nonce"); err == nil {
+		t.Fatal("expected a signature error")
+	}
+}
+
+func TestOIDCCanProvision(t *testing.T) {
+	client := mockOIDC(t, newMockProvider(t))
+
+	for email, want := range map[string]bool{
+		"jane@example.edu":      true,
+		"jane@EXAMPLE.edu":      true,
+		"jane@mail.example.edu": false,
+		"jane@example.com":      false,
+		"jane":                  false,
+	} {
+		if got := client.CanProvision(email); got != want {
+			t.Errorf("CanProvision(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestOIDCNewUser(t *testing.T) {
+	client := mockOIDC(t, newMockProvider(t))
+	identity := &OIDCIdentity{Issuer: "https://idp", Subject: "jane", Email: "jane@example.edu", EmailVerified: true}
+	lookupErr := errors.New("lookup failed")
+
+	tests := []struct {
+		name    string
+		taken   func(string) (bool, error)
+		wantErr error
+	}{
+		{"free username", func(string) (bool, error) { return false, nil }, nil},
+		{"taken username", func(username string) (bool, error) { return username == "jane@example.edu", nil }, ErrOIDCUsernameTaken},
+		{"lookup error", func(string) (bool, error) { return false, lookupErr }, lookupErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := client.NewUser(identity, "faculty", tt.taken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if user != nil {
+					t.Error("expected no user")
+				}
+				return
+			}
+			if user.Username != "jane@example.edu" || user.Role != "faculty" {
+				t.Errorf("user = %s (%s), want jane@example.edu (faculty)", user.Username, user.Role)
+			}
+			if user.ExternalID == nil || *user.ExternalID != "https://idp|jane" {
+				t.Errorf("ExternalID = %v, want https://idp|jane", user.ExternalID)
+			}
+			if user.Password == "" {
+				t.Error("expected a password hash")
+			}
+		})
+	}
+}
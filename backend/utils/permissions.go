@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+	"tms-server/config"
+	"tms-server/models"
+)
+
+// How long role permissions are cached before being reloaded, so edits made
+// through another server instance are picked up
+const permissionCacheTTL = time.Minute
+
+// tenant ID -> role name -> permission set
+type permissionSet map[uint]map[string]map[string]bool
+
+var (
+	permMu      sync.RWMutex
+	rolePerms   permissionSet
+	permsLoaded time.Time
+)
+
+// HasPermission reports whether the tenant's role grants the permission
+func HasPermission(tenantID uint, role, permission string) bool {
+	return permissionsFor(tenantID, role)[permission]
+}
+
+// InvalidatePermissions drops the cache after roles have been edited
+func InvalidatePermissions() {
+	permMu.Lock()
+	rolePerms = nil
+	permMu.Unlock()
+}
+
+func permissionsFor(tenantID uint, role string) map[string]bool {
+	permMu.RLock()
+	perms, fresh := rolePerms, time.Since(permsLoaded) < permissionCacheTTL
+	permMu.RUnlock()
+
+	if perms == nil || !fresh {
+		perms = loadPermissions()
+	}
+
+	roles, ok := perms[tenantID]
+	if !ok {
+		// Before roles have been seeded for the tenant, fall back to the built-in ones
+		roles = defaultPermissions()
+	}
+	return roles[role]
+}
+
+func loadPermissions() permissionSet {
+	permMu.Lock()
+	defer permMu.Unlock()
+
+	if rolePerms != nil && time.Since(permsLoaded) < permissionCacheTTL {
+		return rolePerms
+	}
+
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		log.Printf("Failed to load role permissions: %v", err)
+		if rolePerms != nil {
+			return rolePerms // keep serving the last known permissions
+		}
+		roles = nil
+	}
+
+	perms := make(permissionSet)
+	for _, role := range roles {
+		if perms[role.TenantID] == nil {
+			perms[role.TenantID] = make(map[string]map[string]bool)
+		}
+		set := make(map[string]bool)
+		for _, p := range role.Permissions {
+			set[p.Name] = true
+		}
+		perms[role.TenantID][role.Name] = set
+	}
+
+	rolePerms = perms
+	permsLoaded = time.Now()
+	return perms
+}
+
+func defaultPermissions() map[string]map[string]bool {
+	roles := make(map[string]map[string]bool)
+	for role, names := range models.DefaultRolePermissions {
+		roles[role] = make(map[string]bool)
+		for _, name := range names {
+			roles[role][name] = true
+		}
+	}
+	return roles
+}
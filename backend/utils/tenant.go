@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"tms-server/config"
+	"tms-server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const tenantCacheTTL = time.Minute
+
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose GORM queries are restricted to the tenant
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func TenantFromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	id, ok := ctx.Value(tenantKey{}).(uint)
+	return id, ok
+}
+
+var (
+	tenantMu     sync.RWMutex
+	tenants      []models.Tenant
+	tenantLoaded time.Time
+)
+
+// ResolveTenant finds the tenant for a request from the X-Tenant header, then
+// the host's custom domain or subdomain, falling back to TENANT_DEFAULT.
+// explicit is false when the fallback was used, so a token claim may decide.
+func ResolveTenant(header, host string) (tenant *models.Tenant, explicit bool, err error) {
+	all := loadTenants()
+	if len(all) == 0 {
+		// Not migrated yet: behave as a single-tenant install
+		return &models.Tenant{ID: models.DefaultTenantID, Slug: "default"}, false, nil
+	}
+
+	if header != "" {
+		if t := findTenant(all, func(t models.Tenant) bool { return strings.EqualFold(t.Slug, header) }); t != nil {
+			return t, true, nil
+		}
+		return nil, true, ErrUnknownTenant
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if t := findTenant(all, func(t models.Tenant) bool { return t.Domain != "" && strings.EqualFold(t.Domain, host) }); t != nil {
+		return t, true, nil
+	}
+	if sub, _, ok := strings.Cut(host, "."); ok {
+		if t := findTenant(all, func(t models.Tenant) bool { return strings.EqualFold(t.Slug, sub) }); t != nil {
+			return t, true, nil
+		}
+	}
+
+	slug := envOr("TENANT_DEFAULT", "default")
+	if t := findTenant(all, func(t models.Tenant) bool { return t.Slug == slug }); t != nil {
+		return t, false, nil
+	}
+	return nil, false, ErrUnknownTenant
+}
+
+// TenantByID returns a cached tenant
+func TenantByID(id uint) *models.Tenant {
+	return findTenant(loadTenants(), func(t models.Tenant) bool { return t.ID == id })
+}
+
+func findTenant(all []models.Tenant, match func(models.Tenant) bool) *models.Tenant {
+	for i := range all {
+		if match(all[i]) {
+			return &all[i]
+		}
+	}
+	return nil
+}
+
+func loadTenants() []models.Tenant {
+	tenantMu.RLock()
+	all, fresh := tenants, time.Since(tenantLoaded) < tenantCacheTTL
+	tenantMu.RUnlock()
+	if all != nil && fresh {
+		return all
+	}
+
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+
+	var loaded []models.Tenant
+	if err := config.DB.Find(&loaded).Error; err != nil {
+		log.Printf("Failed to load tenants: %v", err)
+		return tenants // keep serving the last known tenants
+	}
+	tenants = loaded
+	tenantLoaded = time.Now()
+	return tenants
+}
+
+// RegisterTenantScope installs GORM callbacks that restrict every query,
+// update and delete on a model with a tenant_id column to the tenant in the
+// statement's context, and stamp the tenant on created rows. Statements
+// without a tenant in their context (migrations, scripts) are left alone.
+func RegisterTenantScope(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenant:query", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenant:row", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:update", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:delete", tenantWhere); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register("tenant:create", tenantAssign)
+}
+
+func tenantWhere(db *gorm.DB) {
+	tenantID, ok := TenantFromContext(db.Statement.Context)
+	if !ok || db.Statement.Schema == nil || db.Statement.Schema.LookUpField("TenantID") == nil {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"}, Value: tenantID},
+	}})
+}
+
+func tenantAssign(db *gorm.DB) {
+	tenantID, ok := TenantFromContext(db.Statement.Context)
+	if !ok || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("TenantID")
+	if field == nil {
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), tenantID); err != nil {
+				db.AddError(err)
+			}
+		}
+	case reflect.Struct:
+		if err := field.Set(db.Statement.Context, rv, tenantID); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// OriginOfAnyTenant reports whether any tenant lists the origin as its own
+func OriginOfAnyTenant(origin string) bool {
+	for _, t := range loadTenants() {
+		if slices.Contains(TenantOrigins(t.ID), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantOrigins returns the CORS origins for a tenant, or nil to use the
+// global list
+func TenantOrigins(tenantID uint) []string {
+	t := TenantByID(tenantID)
+	if t == nil || t.AllowedOrigins == "" {
+		return nil
+	}
+	var origins []string
+	for _, o := range strings.Split(t.AllowedOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
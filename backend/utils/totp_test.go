@@ -0,0 +1,15 @@
+package utils
+
+import "testing"
+
+// The HOTP test values of RFC 4226, appendix D, which TOTP codes are for
+// their time step
+func TestTOTPCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for step, code := range want {
+		if got := totpCode(key, int64(step)); got != code {
+			t.Errorf("step %d: code = %s, want %s", step, got, code)
+		}
+	}
+}
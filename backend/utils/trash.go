@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"time"
+	"tms-server/config"
+	"tms-server/models"
+
+	"gorm.io/gorm"
+)
+
+// StartTrashPurger permanently deletes trashed rows older than
+// TRASH_RETENTION, checking every TRASH_PURGE_INTERVAL. A zero retention
+// keeps trashed rows forever.
+func StartTrashPurger() {
+	retention := config.GetEnvAsDuration("TRASH_RETENTION", "720h")
+	interval := config.GetEnvAsDuration("TRASH_PURGE_INTERVAL", "24h")
+	if retention <= 0 || interval <= 0 {
+		log.Println("Trash purging disabled")
+		return
+	}
+
+	go func() {
+		for {
+			if purged, err := PurgeTrash(config.DB, retention); err != nil {
+				log.Printf("Failed to purge trash: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d rows deleted more than %s ago", purged, retention)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// PurgeTrash permanently deletes rows of every tenant that were soft-deleted
+// more than retention ago. Rows still referenced through a restrict foreign
+// key, e.g. a course whose trashed subjects aren't due yet, are kept until
+// their dependents are purged. A table that fails is reported and the others
+// are still purged.
+func PurgeTrash(db *gorm.DB, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+
+	var purged int64
+	var errs []error
+	for _, registered := range models.Trashable {
+		// A fresh instance, as statements write back into their model and
+		// the registry's are shared by every caller
+		model := reflect.New(reflect.TypeOf(registered).Elem()).Interface()
+		query, err := unreferenced(db.Unscoped(), model)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result := query.Where("deleted_at < ?", cutoff).Delete(model)
+		if result.Error != nil {
+			errs = append(errs, result.Error)
+			continue
+		}
+		purged += result.RowsAffected
+	}
+	return purged, errors.Join(errs...)
+}
+
+// unreferenced limits a query on model to rows that no row, live or trashed,
+// points at through a restrict foreign key
+func unreferenced(db *gorm.DB, model any) (*gorm.DB, error) {
+	table, err := tableName(db, model)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range models.Dependencies {
+		if dep.OnDelete != models.OnDeleteRestrict || reflect.TypeOf(dep.Parent) != reflect.TypeOf(model) {
+			continue
+		}
+		depTable, err := tableName(db, dep.Model)
+		if err != nil {
+			return nil, err
+		}
+		references := db.Session(&gorm.Session{NewDB: true}).Table(depTable).Select("1").
+			Where(depTable + "." + dep.Column + " = " + table + ".id")
+		db = db.Where("NOT EXISTS (?)", references)
+	}
+	return db, nil
+}
+
+func tableName(db *gorm.DB, model any) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
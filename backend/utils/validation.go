@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is one field of a model breaking one of its validate rules
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+var validate = newValidator()
+
+var hhmm = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// hhmm: a time of day written as HH:MM
+	v.RegisterValidation("hhmm", func(fl validator.FieldLevel) bool {
+		return hhmm.MatchString(fl.Field().String())
+	})
+	// later_than=Field: an HH:MM time after the other field's. Zero-padded
+	// times sort as strings.
+	v.RegisterValidation("later_than", func(fl validator.FieldLevel) bool {
+		other := fl.Parent().FieldByName(fl.Param())
+		return other.IsValid() && fl.Field().String() > other.String()
+	})
+	return v
+}
+
+// ValidateFields checks the validate tags of the named fields of a model.
+// Rules comparing with another field are also checked when only that other
+// field is named, so changing StartTime rechecks EndTime.
+func ValidateFields(model any, fields []string) []FieldError {
+	fields = withDependentFields(reflect.Indirect(reflect.ValueOf(model)).Type(), fields)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	err := validate.StructPartial(model, fields...)
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	errs := make([]FieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		errs = append(errs, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: ruleMessage(fe.Tag(), fe.Param()),
+		})
+	}
+	return errs
+}
+
+func withDependentFields(t reflect.Type, fields []string) []string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			name, param, _ := strings.Cut(rule, "=")
+			if name == "later_than" && slices.Contains(fields, param) && !slices.Contains(fields, field.Name) {
+				fields = append(fields, field.Name)
+			}
+		}
+	}
+	return fields
+}
+
+func ruleMessage(rule, param string) string {
+	switch rule {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "hhmm":
+		return "must be a time as HH:MM"
+	case "later_than":
+		return "must be later than " + param
+	case "gt":
+		return "must be greater than " + param
+	case "gte", "min":
+		return "must be at least " + param
+	case "lte", "max":
+		return "must be at most " + param
+	case "email":
+		return "must be an email address"
+	}
+	return fmt.Sprintf("must satisfy %s", rule)
+}
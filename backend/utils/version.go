@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+)
+
+// RegisterVersioning bumps the Version column of a model on every update,
+// however the update is written, so ETags change whenever the row does
+func RegisterVersioning(db *gorm.DB) error {
+	return db.Callback().Update().Before("gorm:update").Register("version:update", bumpVersion)
+}
+
+func bumpVersion(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.LookUpField("Version") == nil {
+		return
+	}
+	if _, ok := stmt.Clauses["SET"]; ok {
+		return
+	}
+
+	set := callbacks.ConvertToAssignments(stmt)
+	if len(set) == 0 {
+		return
+	}
+	// Save writes the old version back, which the increment replaces
+	set = slices.DeleteFunc(set, func(a clause.Assignment) bool {
+		return a.Column.Name == "version"
+	})
+	stmt.AddClause(append(set, clause.Assignment{
+		Column: clause.Column{Name: "version"},
+		Value:  gorm.Expr("version + 1"),
+	}))
+}
@@ -16,4 +16,24 @@ export const buildBackendUrl = (endpoint) => {
   return endpoint.startsWith('/') ? `${baseUrl}${endpoint}` : `${baseUrl}/${endpoint}`;
 };
 
+// CSRF token required on cookie-authenticated POST/PUT/PATCH/DELETE requests.
+// It comes with the login response, or from GET /csrf for an existing session.
+const CSRF_STORAGE_KEY = 'csrf_token';
+
+export const setCSRFToken = (token) => {
+  if (token) {
+    sessionStorage.setItem(CSRF_STORAGE_KEY, token);
+  } else {
+    sessionStorage.removeItem(CSRF_STORAGE_KEY);
+  }
+};
+
+export const hasCSRFToken = () => sessionStorage.getItem(CSRF_STORAGE_KEY) !== null;
+
+// Headers to add to every state-changing request
+export const csrfHeaders = () => {
+  const token = sessionStorage.getItem(CSRF_STORAGE_KEY);
+  return token ? { 'X-CSRF-Token': token } : {};
+};
+
 export default API_CONFIG;
@@ -4,7 +4,7 @@ import { useNavigate } from "react-router-dom";
 import backendService from "../services/backendservice.js";
 import { useUserRole } from '../context/UserRoleContext';
 import { toast } from 'react-toastify';
-import { buildApiUrl } from '../config/api.js';
+import { buildApiUrl, csrfHeaders, hasCSRFToken, setCSRFToken } from '../config/api.js';
 
 const AuthContext = createContext();
 
@@ -48,6 +48,7 @@ export const AuthProvider = ({ children }) => {
       console.log(data, res.status);
 
       if (res.status === 200) {
+        setCSRFToken(data.csrf_token);
         await fetchUserRole(username);
         toast.success("Login successful!");
         navigate("/dashboard");
@@ -70,11 +71,13 @@ export const AuthProvider = ({ children }) => {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
+          ...csrfHeaders(),
         },
         credentials: "include",
       });
       console.log(res.status);
       if (res.status == 200) {
+        setCSRFToken(null);
         navigate("/");
       } else {
         console.error("Logout failed");
@@ -88,6 +91,16 @@ export const AuthProvider = ({ children }) => {
   //   validateSession();
   // }, []);
 
+  // A session carried over from another tab or an SSO redirect has the
+  // cookie but not the CSRF token that writes need
+  useEffect(() => {
+    if (hasCSRFToken()) return;
+    fetch(buildApiUrl('/csrf'), { credentials: "include" })
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => data && setCSRFToken(data.csrf_token))
+      .catch(() => {});
+  }, []);
+
   return (
     <AuthContext.Provider
       value={{
@@ -12,6 +12,7 @@ import {
 } from "@/components/ui/dialog";
 import { Plus, Edit, Trash2, Save, RefreshCw, Trash, X, Users } from "lucide-react";
 import academicData from "../assets/academicData.json";
+import { csrfHeaders } from "../config/api.js";
 
 const groupConsecutiveTimeSlots = (gridData, days, timeSlots) => {
   const groupedData = {};
@@ -575,6 +576,7 @@ const CreateTimeTable = () => {
           fetch(`${API_ENDPOINTS.LECTURE}/${lecture.ID}`, {
             method: 'PUT',
             headers: {
+              ...csrfHeaders(),
               'Content-Type': 'application/json',
             },
             credentials: 'include',
@@ -592,7 +594,7 @@ const CreateTimeTable = () => {
                 const error = await res.json().catch(() => ({}));
                 return {
                   status: res.status,
-                  message: error.message || res.statusText,
+                  message: error.error?.message || res.statusText,
                   lectureData: lecturesToUpdate[index]
                 };
               }
@@ -612,6 +614,7 @@ const CreateTimeTable = () => {
           fetch(API_ENDPOINTS.LECTURE, {
             method: 'POST',
             headers: {
+              ...csrfHeaders(),
               'Content-Type': 'application/json',
             },
             credentials: 'include',
@@ -624,7 +627,7 @@ const CreateTimeTable = () => {
             const error = await res.json().catch(() => ({}));
             return {
               status: res.status,
-              message: error.message || res.statusText,
+              message: error.error?.message || res.statusText,
               lectureData: lecturesToCreate[index]
             };
           }
@@ -645,6 +648,7 @@ const CreateTimeTable = () => {
           fetch(`${API_ENDPOINTS.LECTURE}/${lecture.ID}`, {
             method: 'DELETE',
             headers: {
+              ...csrfHeaders(),
               'Content-Type': 'application/json',
             },
             credentials: 'include'
@@ -656,7 +660,7 @@ const CreateTimeTable = () => {
             const error = await res.json().catch(() => ({}));
             return {
               status: res.status,
-              message: error.message || res.statusText,
+              message: error.error?.message || res.statusText,
               lectureData: lecturesToDelete[index]
             };
           }
@@ -14,6 +14,7 @@ import {
 import { useUserRole } from "../context/UserRoleContext";
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
+import { csrfHeaders } from "../config/api.js";
 const toastCustomStyles = `
   @media (max-width: 480px) {
     .Toastify__toast {
@@ -164,14 +165,14 @@ const ManageBatches = () => {
 
       const response = await fetch(endpoint, {
         method: method,
-        headers: { "Content-Type": "application/json" },
+        headers: { "Content-Type": "application/json", ...csrfHeaders() },
         credentials: "include",
         body: JSON.stringify(batchData),
       });
 
       if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.error || JSON.stringify(errorData));
+        throw new Error(errorData.error?.message || JSON.stringify(errorData));
       }
 
       await fetchBatches();
@@ -226,6 +227,7 @@ const ManageBatches = () => {
       const response = await fetch(API_ENDPOINTS.DELETE_BATCH(id), {
         method: "DELETE",
         headers: {
+          ...csrfHeaders(),
           "Content-Type": "application/json",
         },
         credentials: "include",
@@ -5,6 +5,7 @@ import { FaEdit, FaTrash, FaPlus, FaTimes, FaGraduationCap, FaSpinner, FaSearch
 import { useUserRole } from "../context/UserRoleContext";
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
+import { csrfHeaders } from "../config/api.js";
 const toastCustomStyles = `
   @media (max-width: 480px) {
     .Toastify__toast {
@@ -28,7 +29,8 @@ const ManageCourses = () => {
     const [newCourse, setNewCourse] = useState({
         id: "",
         name: "",
-        code: ""
+        code: "",
+        duration: ""
     });
  const { userRole } = useUserRole();
     const navigate = useNavigate();
@@ -160,6 +162,12 @@ const ManageCourses = () => {
             return;
         }
 
+        const duration = parseInt(newCourse.duration);
+        if (isNaN(duration) || duration <= 0) {
+            toast.error('Please enter the course duration in years');
+            return;
+        }
+
         try {
             editingCourse ? setEditingCourse(true) : setAddingCourse(true);
 
@@ -167,11 +175,12 @@ const ManageCourses = () => {
                 ? API_ENDPOINTS.UPDATE_COURSE(newCourse.id)
                 : API_ENDPOINTS.ADD_COURSE;
 
-            const method = editingCourse ? 'PUT' : 'POST';
+            const method = editingCourse ? 'PATCH' : 'POST';
 
             const courseData = {
                 name: newCourse.name.trim(),
-                code: newCourse.code.trim().toUpperCase()
+                code: newCourse.code.trim().toUpperCase(),
+                Course_Duration: duration
             };
 
             if (editingCourse) {
@@ -181,6 +190,7 @@ const ManageCourses = () => {
             const response = await fetch(endpoint, {
                 method,
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include', // Include credentials for CORS
@@ -189,7 +199,7 @@ const ManageCourses = () => {
 
             if (!response.ok) {
                 const errorData = await response.json();
-                throw new Error(errorData.error || `Failed to ${editingCourse ? 'update' : 'add'} course`);
+                throw new Error(errorData.error?.message || `Failed to ${editingCourse ? 'update' : 'add'} course`);
             }
 
             await fetchCourses();
@@ -240,6 +250,7 @@ const ManageCourses = () => {
             const response = await fetch(API_ENDPOINTS.DELETE_COURSE(id), {
                 method: 'DELETE',
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include' // Include credentials for CORS
@@ -263,7 +274,8 @@ const ManageCourses = () => {
         setNewCourse({
             id: course.ID,
             name: course.Name,
-            code: course.Code
+            code: course.Code,
+            duration: course.Course_Duration ? course.Course_Duration.toString() : ""
         });
         setEditingCourse(true);
         setShowAddDialog(true);
@@ -283,7 +295,8 @@ const ManageCourses = () => {
         setNewCourse({
             id: "",
             name: "",
-            code: ""
+            code: "",
+            duration: ""
         });
         setEditingCourse(false);
     };
@@ -562,10 +575,26 @@ const ManageCourses = () => {
                                 />
                             </div>
 
+                            <div>
+                                <label htmlFor="courseDuration" className="block text-sm font-medium text-slate-700 mb-2">
+                                    Duration (years) *
+                                </label>
+                                <input
+                                    id="courseDuration"
+                                    type="number"
+                                    min="1"
+                                    value={newCourse.duration}
+                                    onChange={(e) => setNewCourse(prev => ({ ...prev, duration: e.target.value }))}
+                                    placeholder="Enter course duration (e.g., 4)"
+                                    disabled={addingCourse}
+                                    className="w-full px-4 py-3 border border-slate-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-colors duration-200 disabled:bg-slate-100 disabled:cursor-not-allowed"
+                                />
+                            </div>
+
                             <div className="flex space-x-3 pt-4">
                                 <button
                                     onClick={handleSaveCourse}
-                                    disabled={!newCourse.name.trim() || !newCourse.code.trim() || addingCourse}
+                                    disabled={!newCourse.name.trim() || !newCourse.code.trim() || !newCourse.duration || addingCourse}
                                     className="flex-1 bg-gradient-to-r from-blue-500 to-indigo-600 hover:from-blue-600 hover:to-indigo-700 disabled:from-slate-300 disabled:to-slate-400 text-white py-3 px-4 rounded-lg transition-all duration-200 font-medium disabled:cursor-not-allowed flex items-center justify-center space-x-2"
                                 >
                                     {addingCourse ? (
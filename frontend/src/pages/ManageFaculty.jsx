@@ -5,6 +5,7 @@ import { FaEdit, FaTrash, FaPlus, FaTimes, FaUserTie, FaSpinner, FaSearch } from
 import { useUserRole } from "../context/UserRoleContext";
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
+import { csrfHeaders } from "../config/api.js";
 const toastCustomStyles = `
   @media (max-width: 480px) {
     .Toastify__toast {
@@ -97,7 +98,7 @@ const ManageFaculty = () => {
                 ? API_ENDPOINTS.UPDATE_FACULTY(newFaculty.ID)
                 : API_ENDPOINTS.ADD_FACULTY;
 
-            const method = editingFaculty ? 'PUT' : 'POST';
+            const method = editingFaculty ? 'PATCH' : 'POST';
 
             const facultyData = {
                 Name: newFaculty.Name.trim()
@@ -110,6 +111,7 @@ const ManageFaculty = () => {
             const response = await fetch(endpoint, {
                 method,
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include', // Include cookies for session management
@@ -118,7 +120,7 @@ const ManageFaculty = () => {
 
             if (!response.ok) {
                 const errorData = await response.json();
-                throw new Error(errorData.error || `Failed to ${editingFaculty ? 'update' : 'add'} faculty`);
+                throw new Error(errorData.error?.message || `Failed to ${editingFaculty ? 'update' : 'add'} faculty`);
             }
 
             await fetchFaculties();
@@ -170,6 +172,7 @@ const ManageFaculty = () => {
             const response = await fetch(API_ENDPOINTS.DELETE_FACULTY(id), {
                 method: 'DELETE',
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include' // Include cookies for session management
@@ -5,6 +5,7 @@ import { FaEdit, FaTrash, FaPlus, FaTimes, FaBuilding, FaSpinner, FaSearch } fro
 import { useUserRole } from "../context/UserRoleContext";
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
+import { csrfHeaders } from "../config/api.js";
 const toastCustomStyles = `
   @media (max-width: 480px) {
     .Toastify__toast {
@@ -110,6 +111,7 @@ const ManageRooms = () => {
             const response = await fetch(endpoint, {
                 method,
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include', // Include cookies for session management
@@ -118,7 +120,7 @@ const ManageRooms = () => {
 
             if (!response.ok) {
                 const errorData = await response.json();
-                throw new Error(errorData.error || `Failed to ${editingRoom ? 'update' : 'add'} room`);
+                throw new Error(errorData.error?.message || `Failed to ${editingRoom ? 'update' : 'add'} room`);
             }
 
             await fetchRooms();
@@ -172,6 +174,7 @@ const ManageRooms = () => {
             const response = await fetch(API_ENDPOINTS.DELETE_ROOM(id), {
                 method: 'DELETE',
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include' // Include cookies for session management
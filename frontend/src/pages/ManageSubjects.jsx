@@ -5,6 +5,7 @@ import { FaEdit, FaTrash, FaPlus, FaTimes, FaBook, FaGraduationCap, FaSpinner, F
 import { useUserRole } from "../context/UserRoleContext";
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
+import { csrfHeaders } from "../config/api.js";
 const toastCustomStyles = `
   @media (max-width: 480px) {
     .Toastify__toast {
@@ -136,14 +137,14 @@ const handleSaveNewSubject = async () => {
 
         const response = await fetch(endpoint, {
             method: method,
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', ...csrfHeaders() },
             credentials: 'include',
             body: JSON.stringify(subjectData)
         });
 
         if (!response.ok) {
             const errorData = await response.json();
-            throw new Error(errorData.error || JSON.stringify(errorData));
+            throw new Error(errorData.error?.message || JSON.stringify(errorData));
         }
 
         await fetchSubjects();
@@ -195,6 +196,7 @@ const handleSaveNewSubject = async () => {
             const response = await fetch(API_ENDPOINTS.DELETE_SUBJECT(id), {
                 method: 'DELETE',
                 headers: {
+                    ...csrfHeaders(),
                     'Content-Type': 'application/json',
                 },
                 credentials: 'include'
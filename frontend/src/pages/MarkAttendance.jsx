@@ -18,6 +18,7 @@ import {
 } from "lucide-react";
 import { GiTeacher } from "react-icons/gi";
 import academicData from "../assets/academicData.json";
+import { csrfHeaders } from "../config/api.js";
 const Spinner = ({ className = "w-12 h-12" }) => (
   <svg
     className={`animate-spin text-blue-500 ${className}`}
@@ -361,8 +362,9 @@ function MarkLectures() {
   const markLectures = async (sessionId, newStatus) => {
     try {
       const response = await fetch(`${API_BASE_URL}/session/${sessionId}`, {
-        method: 'PUT',
+        method: 'PATCH',
         headers: {
+          ...csrfHeaders(),
           'Content-Type': 'application/json',
           'Accept': 'application/json'
         },
@@ -14,6 +14,7 @@ import {
   Clock,
   MapPin,
 } from "lucide-react";
+import { csrfHeaders } from "../config/api.js";
 
 const Spinner = ({ className = "w-12 h-12" }) => (
   <svg
@@ -421,8 +422,9 @@ function ViewCalendar() {
     try {
       const sessionId = selectedLecture.session_id;
       const response = await fetch(`${API_BASE_URL}/session/${sessionId}`, {
-        method: 'PUT',
+        method: 'PATCH',
         headers: {
+          ...csrfHeaders(),
           'Content-Type': 'application/json',
           'Accept': 'application/json'
         },
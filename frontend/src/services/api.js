@@ -1,11 +1,18 @@
 import axios from "axios";
-import { API_CONFIG } from "../config/api.js";
+import { API_CONFIG, csrfHeaders } from "../config/api.js";
 
 const instance = axios.create({
   baseURL: API_CONFIG.BACKEND_URL,
   withCredentials: true,
 });
 
+instance.interceptors.request.use((config) => {
+  if (!["get", "head", "options"].includes((config.method || "get").toLowerCase())) {
+    Object.assign(config.headers, csrfHeaders());
+  }
+  return config;
+});
+
 instance.interceptors.response.use(
   (res) => res,
   (err) => {